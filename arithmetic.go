@@ -0,0 +1,164 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import "math"
+
+const (
+	// ArithmeticTypeNone indicates no arithmetic operation, i.e. the value is a selector or number
+	ArithmeticTypeNone ArithmeticType = 0
+	// ArithmeticTypeAdd indicates an addition
+	ArithmeticTypeAdd ArithmeticType = 1
+	// ArithmeticTypeSubtract indicates a subtraction
+	ArithmeticTypeSubtract ArithmeticType = 2
+	// ArithmeticTypeMultiply indicates a multiplication
+	ArithmeticTypeMultiply ArithmeticType = 3
+	// ArithmeticTypeDivide indicates a division
+	ArithmeticTypeDivide ArithmeticType = 4
+	// ArithmeticTypeModulo indicates the remainder of a division
+	ArithmeticTypeModulo ArithmeticType = 5
+	// ArithmeticTypeNegate indicates a negation of the left value
+	ArithmeticTypeNegate ArithmeticType = 6
+)
+
+// String returns a string representation of the arithmetic operation
+func (a *ArithmeticType) String() string {
+	switch *a {
+	case ArithmeticTypeAdd:
+		return "+"
+	case ArithmeticTypeSubtract, ArithmeticTypeNegate:
+		return "-"
+	case ArithmeticTypeMultiply:
+		return "*"
+	case ArithmeticTypeDivide:
+		return "/"
+	case ArithmeticTypeModulo:
+		return "%"
+	}
+
+	return "none"
+}
+
+// getArithmetic converts the token id to the arithmetic operation
+func getArithmetic(id TokenID) ArithmeticType {
+	switch id {
+	case ArithmeticAdd:
+		return ArithmeticTypeAdd
+	case ArithmeticSubtract:
+		return ArithmeticTypeSubtract
+	case ArithmeticMultiply:
+		return ArithmeticTypeMultiply
+	case ArithmeticDivide:
+		return ArithmeticTypeDivide
+	case ArithmeticModulo:
+		return ArithmeticTypeModulo
+	case ArithmeticNegate:
+		return ArithmeticTypeNegate
+	}
+
+	return ArithmeticTypeNone
+}
+
+// apply is responsible for applying the arithmetic operation to the left and right values,
+// returning false when there is no outcome i.e. a division by zero
+func (a ArithmeticType) apply(left, right float64) (float64, bool) {
+	switch a {
+	case ArithmeticTypeAdd:
+		return left + right, true
+	case ArithmeticTypeSubtract:
+		return left - right, true
+	case ArithmeticTypeMultiply:
+		return left * right, true
+	case ArithmeticTypeDivide:
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	case ArithmeticTypeModulo:
+		if right == 0 {
+			return 0, false
+		}
+		return math.Mod(left, right), true
+	case ArithmeticTypeNegate:
+		return -left, true
+	}
+
+	return 0, false
+}
+
+// isConstant checks if the value is a number
+func (v *Value) isConstant() bool {
+	return v.Selector == "" && v.Call == nil && v.Literal == nil && v.Arithmetic == ArithmeticTypeNone
+}
+
+// resolve is responsible for resolving the values of the selector or function call, or
+// computing the value of the arithmetic operation
+func (v *Value) resolve(fn ValueFn) ([]interface{}, error) {
+	if v.Arithmetic == ArithmeticTypeNone {
+		switch {
+		case v.Literal != nil:
+			return []interface{}{v.Literal}, nil
+		case v.Call != nil:
+			return v.Call.evaluate(fn)
+		case v.Selector != "":
+			return fn(v.Selector)
+		}
+	}
+	x, known, err := v.evaluate(fn)
+	if err != nil || !known {
+		return nil, err
+	}
+
+	return []interface{}{x}, nil
+}
+
+// evaluate is responsible for computing the value, the selectors are resolved via the value
+// function. A selector or function call must have a single numeric value, otherwise the value
+// is unknown and false is returned, as it is for a division by zero
+func (v *Value) evaluate(fn ValueFn) (float64, bool, error) {
+	switch v.Arithmetic {
+	case ArithmeticTypeNone:
+		if v.isConstant() {
+			return v.Number, true, nil
+		}
+		values, err := v.resolve(fn)
+		if err != nil || len(values) != 1 {
+			return 0, false, err
+		}
+		x, found := toFloat(values[0])
+
+		return x, found, nil
+	case ArithmeticTypeNegate:
+		x, known, err := v.Left.evaluate(fn)
+		if err != nil || !known {
+			return 0, false, err
+		}
+
+		return -x, true, nil
+	}
+	left, known, err := v.Left.evaluate(fn)
+	if err != nil || !known {
+		return 0, false, err
+	}
+	right, known, err := v.Right.evaluate(fn)
+	if err != nil || !known {
+		return 0, false, err
+	}
+	x, known := v.Arithmetic.apply(left, right)
+
+	return x, known, nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArithmeticString(t *testing.T) {
+	cs := []struct {
+		Arithmetic ArithmeticType
+		Expected   string
+	}{
+		{Arithmetic: ArithmeticTypeAdd, Expected: "+"},
+		{Arithmetic: ArithmeticTypeSubtract, Expected: "-"},
+		{Arithmetic: ArithmeticTypeMultiply, Expected: "*"},
+		{Arithmetic: ArithmeticTypeDivide, Expected: "/"},
+		{Arithmetic: ArithmeticTypeModulo, Expected: "%"},
+		{Arithmetic: ArithmeticTypeNegate, Expected: "-"},
+		{Arithmetic: ArithmeticTypeNone, Expected: "none"},
+	}
+	for _, c := range cs {
+		assert.Equal(t, c.Expected, c.Arithmetic.String())
+	}
+}
+
+func TestGetArithmetic(t *testing.T) {
+	assert.Equal(t, ArithmeticTypeAdd, getArithmetic(ArithmeticAdd))
+	assert.Equal(t, ArithmeticTypeSubtract, getArithmetic(ArithmeticSubtract))
+	assert.Equal(t, ArithmeticTypeMultiply, getArithmetic(ArithmeticMultiply))
+	assert.Equal(t, ArithmeticTypeDivide, getArithmetic(ArithmeticDivide))
+	assert.Equal(t, ArithmeticTypeModulo, getArithmetic(ArithmeticModulo))
+	assert.Equal(t, ArithmeticTypeNegate, getArithmetic(ArithmeticNegate))
+	assert.Equal(t, ArithmeticTypeNone, getArithmetic(Match))
+}
+
+func TestArithmeticApply(t *testing.T) {
+	cs := []struct {
+		Arithmetic ArithmeticType
+		Left       float64
+		Right      float64
+		Expected   float64
+		Known      bool
+	}{
+		{Arithmetic: ArithmeticTypeAdd, Left: 1, Right: 2, Expected: 3, Known: true},
+		{Arithmetic: ArithmeticTypeSubtract, Left: 1, Right: 2, Expected: -1, Known: true},
+		{Arithmetic: ArithmeticTypeMultiply, Left: 3, Right: 2, Expected: 6, Known: true},
+		{Arithmetic: ArithmeticTypeDivide, Left: 3, Right: 2, Expected: 1.5, Known: true},
+		{Arithmetic: ArithmeticTypeModulo, Left: 7, Right: 4, Expected: 3, Known: true},
+		{Arithmetic: ArithmeticTypeModulo, Left: -7, Right: 4, Expected: -3, Known: true},
+		{Arithmetic: ArithmeticTypeNegate, Left: 3, Expected: -3, Known: true},
+		{Arithmetic: ArithmeticTypeDivide, Left: 3},
+		{Arithmetic: ArithmeticTypeModulo, Left: 3},
+		{Arithmetic: ArithmeticTypeNone, Left: 3},
+	}
+	for i, c := range cs {
+		v, known := c.Arithmetic.apply(c.Left, c.Right)
+		assert.Equal(t, c.Known, known, "case %d", i)
+		assert.Equal(t, c.Expected, v, "case %d", i)
+	}
+}
+
+func TestValueEvaluate(t *testing.T) {
+	values := map[string][]interface{}{
+		"used":  {45},
+		"total": {"50"},
+		"zero":  {0},
+		"name":  {"test"},
+		"tags":  {1, 2},
+		"none":  {nil},
+	}
+	fn := func(name string) ([]interface{}, error) {
+		if name == "error" {
+			return nil, errors.New("failed")
+		}
+		return values[name], nil
+	}
+	used, total := &Value{Selector: "used"}, &Value{Selector: "total"}
+	cs := []struct {
+		Value    *Value
+		Expected float64
+		Known    bool
+		Error    bool
+	}{
+		{Value: &Value{Number: 2}, Expected: 2, Known: true},
+		{Value: used, Expected: 45, Known: true},
+		{Value: &Value{Arithmetic: ArithmeticTypeDivide, Left: used, Right: total}, Expected: 0.9, Known: true},
+		{Value: &Value{Arithmetic: ArithmeticTypeNegate, Left: used}, Expected: -45, Known: true},
+		{Value: &Value{Arithmetic: ArithmeticTypeDivide, Left: used, Right: &Value{Selector: "zero"}}},
+		{Value: &Value{Arithmetic: ArithmeticTypeAdd, Left: used, Right: &Value{Selector: "name"}}},
+		{Value: &Value{Arithmetic: ArithmeticTypeAdd, Left: &Value{Selector: "tags"}, Right: used}},
+		{Value: &Value{Arithmetic: ArithmeticTypeAdd, Left: used, Right: &Value{Selector: "none"}}},
+		{Value: &Value{Arithmetic: ArithmeticTypeNegate, Left: &Value{Selector: "missing"}}},
+		{Value: &Value{Arithmetic: ArithmeticTypeAdd, Left: used, Right: &Value{Selector: "error"}}, Error: true},
+	}
+	for i, c := range cs {
+		v, known, err := c.Value.evaluate(fn)
+		if c.Error {
+			assert.Error(t, err, "case %d should have failed", i)
+			continue
+		}
+		assert.NoError(t, err, "case %d", i)
+		assert.Equal(t, c.Known, known, "case %d", i)
+		assert.Equal(t, c.Expected, v, "case %d", i)
+	}
+}
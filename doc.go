@@ -16,6 +16,11 @@ limitations under the License.
 
 package lex
 
+import (
+	"net/netip"
+	"sync"
+)
+
 // Lexer is actual parser
 type Lexer struct {
 	// a list of token channels use to send token ok
@@ -24,9 +29,24 @@ type Lexer struct {
 	validFn exprValidFn
 	// the input for the lexer
 	input string
+	// the functions which can be called in addition to the built-ins
+	functions *FunctionRegistry
+	// the operations which can be used in addition to the built-ins
+	operators *OperatorRegistry
+}
+
+// Option is a function which configures the lexer
+type Option func(*Lexer)
+
+// Program is a compiled expression which can be evaluated many times
+type Program struct {
+	// the input the program was compiled from
+	input string
+	// the parsed expression
+	root *Group
 }
 
-// ValueFn is the callback function used by the expression evaluation
+// ValueFn is the callback function used by the expression evaluation to resolve a selector into its values
 type ValueFn func(string) ([]interface{}, error)
 
 // OperationID is the expression operation
@@ -35,28 +55,148 @@ type OperationID int
 // LogicType is a logical operation type, i.e. AND or OR
 type LogicType int
 
+// ValueType is the type of the values of a function parameter or result
+type ValueType int
+
+// ArithmeticType is an arithmetic operation type, i.e. addition or multiplication
+type ArithmeticType int
+
+// QuantifierType defines how many of the selector values must satisfy the operation
+type QuantifierType int
+
 // Expression is a lex expression
 type Expression struct {
 	// Selector is the expression selector
 	Selector string
+	// Quantifier indicates if any or all of the selector values must match
+	Quantifier QuantifierType
 	// Operation is the expression operation
 	Operation OperationID
 	// Match is what the input is being compared to
 	Match interface{}
-	// Logic indicates a logical operation
-	Logic LogicType
-	// Next is the next statement
-	Next *Expression
+	// Value is the arithmetic expression compared in place of the selector
+	Value *Value
+	// Operator is the registered operation when the operation is custom
+	Operator *Operator
 }
 
-// Group is a collection of expressions
+// Value is a node in an arithmetic expression, either a selector, a number, a function call
+// or an arithmetic operation between two values
+type Value struct {
+	// Selector is the selector the value is resolved from, empty for a number
+	Selector string
+	// Call is the function call the value is resolved from
+	Call *Call
+	// Literal is the string when the value is a quoted string, which is an argument of a function
+	Literal interface{}
+	// Number is the value when it is a constant
+	Number float64
+	// Arithmetic indicates the arithmetic operation between the left and right values
+	Arithmetic ArithmeticType
+	// Left is the left hand side of the arithmetic operation, or the value negated
+	Left *Value
+	// Right is the right hand side of the arithmetic operation
+	Right *Value
+}
+
+// Call is a call of a function on the values of its arguments i.e. lower(user.name)
+type Call struct {
+	// Name is the name of the function
+	Name string
+	// Arguments are the values the function is called with
+	Arguments []*Value
+	// the function being called
+	function *Function
+}
+
+// Function is a function which can be called in an expression i.e. has_role(user, "admin")
+type Function struct {
+	// Parameters are the types of the arguments the function takes
+	Parameters []ValueType
+	// Returns is the type of the values the function returns
+	Returns ValueType
+	// Call is invoked with the values of each argument, converted to the type of the parameter,
+	// and returns the values of the call
+	Call func(arguments [][]interface{}) ([]interface{}, error)
+}
+
+// FunctionRegistry is a set of functions which can be called in an expression
+type FunctionRegistry struct {
+	// the lock for the functions
+	lock sync.RWMutex
+	// the functions keyed by name
+	functions map[string]*Function
+}
+
+// Operator is a comparison operation which can be registered for use in an expression
+// i.e. name ~~ "jon" or location within london
+type Operator struct {
+	// Symbol is the symbol or word of the operation in the input i.e. '~~' or 'within'
+	Symbol string
+	// Precedence orders the matching of the symbols which overlap, the higher is matched
+	// first and then the longest i.e. '~~>' before '~~'. The operation binds as the built-in
+	// comparisons do, looser than arithmetic and tighter than && and || i.e. a + 1 ~~ b && c
+	// is ((a + 1) ~~ b) && c
+	Precedence int
+	// Left is the type of the values of the selector the operation takes
+	Left ValueType
+	// Right is the type of the match the operation takes
+	Right ValueType
+	// Evaluate is invoked with each value of the selector and the match, converted to their
+	// types, returning true when the operation is satisfied
+	Evaluate func(value, match interface{}) (bool, error)
+}
+
+// OperatorRegistry is a set of operations which can be used in an expression
+type OperatorRegistry struct {
+	// the lock for the operators
+	lock sync.RWMutex
+	// the operators keyed by symbol
+	operators map[string]*Operator
+}
+
+// List is a list of values used by the membership operations
+type List struct {
+	// Values are the values of the list in the order given
+	Values []interface{}
+	// the values of the list keyed for lookups
+	set map[interface{}]bool
+	// the network prefixes in the list
+	prefixes []netip.Prefix
+}
+
+// Null is the match of the null literal, a selector is null when it has no values
+type Null struct{}
+
+// Reference is the match of a selector prefixed with a dollar, the value of the selector it
+// names is compared i.e. end_time > $start_time
+type Reference string
+
+// Version is a semantic version i.e. v1.12.0 or 2.0.0-rc.1+build.5
+type Version struct {
+	// Major is the major version
+	Major uint64
+	// Minor is the minor version
+	Minor uint64
+	// Patch is the patch version
+	Patch uint64
+	// Prerelease are the dot separated identifiers of the pre-release
+	Prerelease []string
+	// Build is the build metadata, which is ignored for precedence
+	Build string
+}
+
+// Group is a node in the expression tree, either a single expression or a logical
+// operation between two groups
 type Group struct {
-	// Expressions is a collection of expressions which group to make the statement
+	// Expression is the expression when the group has no logical operation
 	Expression *Expression
-	// Logic indicates a logical operation between groups
+	// Logic indicates the logical operation between the left and right groups
 	Logic LogicType
-	// Next is the next statement
-	Next *Group
+	// Left is the left hand side of the logical operation
+	Left *Group
+	// Right is the right hand side of the logical operation
+	Right *Group
 }
 
 // TokenID is the token type
@@ -74,8 +214,45 @@ type Token struct {
 	End int
 }
 
-// TokenChannel is a channel used to send token upstream
+// ErrorCode identifies the class of a parse error
+type ErrorCode int
+
+// ParseError is the error returned when the input cannot be parsed
+type ParseError struct {
+	// Code is the class of the error
+	Code ErrorCode
+	// Message is a description of the error
+	Message string
+	// Offset is the byte offset of the error in the input, which is the position given in
+	// the message and the start of the span marked
+	Offset int
+	// Line is the line in the input the error is on, starting at one
+	Line int
+	// Column is the character in the line the error is at, starting at one
+	Column int
+	// Token is the offending token
+	Token Token
+	// Expected is the set of tokens which were permitted in place of the token
+	Expected []TokenID
+	// Hint is a suggested correction for the token if we have one
+	Hint string
+	// the input the error was found in
+	source string
+	// the start and end of the span in the input to mark
+	span [2]int
+	// the format and arguments of the message, formatted again once located
+	format string
+	args   []interface{}
+}
+
+// ParseErrors is a collection of the errors found when parsing with recovery
+type ParseErrors []*ParseError
+
+// TokenChannel is a channel used to send the tokens to the listeners
 type TokenChannel chan Token
 
+// truth is the outcome of a three valued evaluation i.e. true, false or unknown
+type truth int
+
 // exprValidFn is a function which validates the expression
 type exprValidFn func(string) error
@@ -16,11 +16,266 @@ limitations under the License.
 
 package lex
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
 
 var (
 	// ErrInvalidExpressionEqaulity means the expression match is invalid i.e. == >= etc
 	ErrInvalidExpressionEqaulity = errors.New("invalid expression equality")
-	// ErrInvalidExpression means the expression is invalid
+	// ErrInvalidExpression means the expression is invalid, all parse errors wrap this error
 	ErrInvalidExpression = errors.New("invalid expression")
 )
+
+const (
+	// CodeUnexpectedToken means the token is not permitted in the position it was found
+	CodeUnexpectedToken ErrorCode = iota + 1
+	// CodeUnexpectedEnd means the input ended before the expression was complete
+	CodeUnexpectedEnd
+	// CodeUnclosedGroup means a group was opened but never closed
+	CodeUnclosedGroup
+	// CodeUnopenedGroup means a group was closed but never opened
+	CodeUnopenedGroup
+	// CodeInvalidValue means the match is not valid for the operation
+	CodeInvalidValue
+	// CodeInvalidRegex means the regex is not enclosed or fails to compile
+	CodeInvalidRegex
+	// CodeInvalidString means the string literal is not terminated or has a bad escape sequence
+	CodeInvalidString
+	// CodeInvalidGlob means the glob pattern is malformed
+	CodeInvalidGlob
+	// CodeUnknownFunction means the function called is not defined
+	CodeUnknownFunction
+	// CodeInvalidArguments means the function was called with the wrong number of arguments
+	CodeInvalidArguments
+)
+
+// String returns a string representation of the error code
+func (c ErrorCode) String() string {
+	switch c {
+	case CodeUnexpectedToken:
+		return "unexpected token"
+	case CodeUnexpectedEnd:
+		return "unexpected end"
+	case CodeUnclosedGroup:
+		return "unclosed group"
+	case CodeUnopenedGroup:
+		return "unopened group"
+	case CodeInvalidValue:
+		return "invalid value"
+	case CodeInvalidRegex:
+		return "invalid regex"
+	case CodeInvalidString:
+		return "invalid string"
+	case CodeInvalidGlob:
+		return "invalid glob"
+	case CodeUnknownFunction:
+		return "unknown function"
+	case CodeInvalidArguments:
+		return "invalid arguments"
+	}
+
+	return "unknown"
+}
+
+// newParseError creates a parse error for the token, the line and column, along with the
+// position given in the message, are filled in once the error is located in the input
+func newParseError(code ErrorCode, token Token, expected []TokenID, format string, args ...interface{}) *ParseError {
+	return &ParseError{
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Offset:   token.Start,
+		Token:    token,
+		Expected: expected,
+		format:   format,
+		args:     args,
+	}
+}
+
+// Error returns the description of the error
+func (e *ParseError) Error() string {
+	return e.Message
+}
+
+// Unwrap allows the parse errors to be matched against ErrInvalidExpression
+func (e *ParseError) Unwrap() error {
+	return ErrInvalidExpression
+}
+
+// locate is responsible for pointing the error at the first non-whitespace character of
+// the token and calculating the line and column of the span marked in the input
+func (e *ParseError) locate(input string) {
+	for e.Offset < e.Token.End && e.Offset < len(input) && isSpace(input[e.Offset]) {
+		e.Offset++
+	}
+	if e.Offset > len(input) {
+		e.Offset = len(input)
+	}
+	// step: mark the token, or the mistyped operator if we have a suggestion
+	e.source = input
+	e.span = [2]int{e.Offset, e.Token.End}
+	if e.span[1] < e.span[0] {
+		e.span[1] = e.span[0]
+	}
+	if hint, begin, end := suggest(input, e.Offset); hint != "" {
+		e.Hint = hint
+		e.span = [2]int{begin, end}
+	}
+	// step: the offset, line, column and the position in the message are of the span, so
+	// they agree with the marker
+	e.Offset = e.span[0]
+	if i := positionOf(e.format); i >= 0 && i < len(e.args) {
+		args := append([]interface{}{}, e.args...)
+		args[i] = e.Offset
+		e.Message = fmt.Sprintf(e.format, args...)
+	}
+	e.Line, e.Column = 1, 1
+	for _, c := range input[:e.span[0]] {
+		if c == '\n' {
+			e.Line++
+			e.Column = 1
+			continue
+		}
+		e.Column++
+	}
+}
+
+// Render returns the error with the offending line of the input, a marker underneath the
+// failing span and a suggestion if we have one, i.e.
+//
+//	line 2, column 5: '=' is missing a value at position: 14
+//	  b => 1
+//	    ^~
+//	did you mean '>='?
+func (e *ParseError) Render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "line %d, column %d: %s", e.Line, e.Column, e.Message)
+
+	// step: find the line the span starts on
+	begin := strings.LastIndexByte(e.source[:e.span[0]], '\n') + 1
+	end := len(e.source)
+	if i := strings.IndexByte(e.source[e.span[0]:], '\n'); i >= 0 {
+		end = e.span[0] + i
+	}
+	b.WriteString("\n" + e.source[begin:end] + "\n")
+
+	// step: pad up to the span, keeping the tabs so the marker lines up
+	for _, c := range e.source[begin:e.span[0]] {
+		if c == '\t' {
+			b.WriteByte('\t')
+			continue
+		}
+		b.WriteByte(' ')
+	}
+	if e.span[1] < end {
+		end = e.span[1]
+	}
+	b.WriteString("^")
+	if width := utf8.RuneCountInString(strings.TrimSpace(e.source[e.span[0]:end])); width > 1 {
+		b.WriteString(strings.Repeat("~", width-1))
+	}
+	if e.Hint != "" {
+		fmt.Fprintf(&b, "\ndid you mean '%s'?", e.Hint)
+	}
+
+	return b.String()
+}
+
+// Error returns the description of the errors
+func (e ParseErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	list := make([]string, len(e))
+	for i, x := range e {
+		list[i] = x.Error()
+	}
+
+	return fmt.Sprintf("%d errors found: %s", len(e), strings.Join(list, "; "))
+}
+
+// Unwrap returns the errors so they can be inspected with errors.Is and errors.As
+func (e ParseErrors) Unwrap() []error {
+	list := make([]error, len(e))
+	for i, x := range e {
+		list[i] = x
+	}
+
+	return list
+}
+
+// Render returns the rendering of each of the errors
+func (e ParseErrors) Render() string {
+	list := make([]string, len(e))
+	for i, x := range e {
+		list[i] = x.Render()
+	}
+
+	return strings.Join(list, "\n\n")
+}
+
+// suggestions are the corrections for operators commonly mistyped
+var suggestions = map[string]string{
+	"===": "==",
+	"!==": "!=",
+	"=!":  "!=",
+	"<>":  "!=",
+	"=>":  ">=",
+	"=<":  "<=",
+	"==~": "=~",
+	"!~":  "!=",
+	"&":   "&&",
+	"&&&": "&&",
+	"|":   "||",
+	"|||": "||",
+}
+
+// positionOf returns the index of the argument which is the position in the message format,
+// or -1 if the message does not give one
+func positionOf(format string) int {
+	i := strings.Index(format, "position: %d")
+	if i < 0 {
+		return -1
+	}
+
+	return strings.Count(format[:i], "%") - 2*strings.Count(format[:i], "%%")
+}
+
+// suggest is responsible for finding a correction for the operator at the offset, returning
+// the suggestion and the span of the operator
+func suggest(input string, offset int) (string, int, int) {
+	isOperator := func(c byte) bool {
+		return strings.IndexByte("=!<>~&|", c) >= 0
+	}
+	begin, end := offset, offset
+	for begin > 0 && isOperator(input[begin-1]) {
+		begin--
+	}
+	for end < len(input) && isOperator(input[end]) {
+		end++
+	}
+
+	return suggestions[input[begin:end]], begin, end
+}
+
+// expectedTokens returns the tokens which are permitted to follow the token
+func expectedTokens(id TokenID) []TokenID {
+	var list []TokenID
+	for x, filter := range parsingRules {
+		if validateTokenRules(id, filter) {
+			list = append(list, x)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+
+	return list
+}
+
+// isSpace checks if the character is whitespace
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseError(t *testing.T) {
+	cs := []struct {
+		Input    string
+		Code     ErrorCode
+		Offset   int
+		Line     int
+		Column   int
+		Value    string
+		Expected []TokenID
+	}{
+		{
+			Input:    "test == 1)",
+			Code:     CodeUnopenedGroup,
+			Offset:   9,
+			Line:     1,
+			Column:   10,
+			Value:    ")",
+			Expected: []TokenID{EOF, LogicalAnd, LogicalOr},
+		},
+		{
+			Input:    "(test == 1",
+			Code:     CodeUnclosedGroup,
+			Offset:   0,
+			Line:     1,
+			Column:   1,
+			Value:    "(",
+			Expected: []TokenID{CloseGroup},
+		},
+		{
+			Input:    "test > abc",
+			Code:     CodeInvalidValue,
+			Offset:   7,
+			Line:     1,
+			Column:   8,
+			Value:    "abc",
+			Expected: []TokenID{Match},
+		},
+		{
+			Input:    "a == 1 &&\n  b >= x",
+			Code:     CodeInvalidValue,
+			Offset:   17,
+			Line:     2,
+			Column:   8,
+			Value:    "x",
+			Expected: []TokenID{Match},
+		},
+		{
+			Input:    "a == 1 && && b == 1",
+			Code:     CodeUnexpectedToken,
+			Offset:   10,
+			Line:     1,
+			Column:   11,
+			Value:    "&&",
+			Expected: []TokenID{Expr, OpenGroup, Quantifier, LogicalNot, Exists, Missing, ArithmeticNegate, FunctionCall},
+		},
+		{
+			Input:    "a == 1 &&\n  b => 1",
+			Code:     CodeInvalidValue,
+			Offset:   14,
+			Line:     2,
+			Column:   5,
+			Value:    "",
+			Expected: []TokenID{Match, Literal, Boolean, Duration, Timestamp, SemVer, IPAddress, CIDR},
+		},
+		{
+			Input:    `a == "bad\q"`,
+			Code:     CodeInvalidString,
+			Offset:   5,
+			Line:     1,
+			Column:   6,
+			Value:    `"bad\q"`,
+			Expected: []TokenID{Literal},
+		},
+		{
+			Input:    "a == 1 && && b == 2",
+			Code:     CodeUnexpectedToken,
+			Offset:   10,
+			Line:     1,
+			Column:   11,
+			Value:    "&&",
+			Expected: expectedTokens(LogicalAnd),
+		},
+		{
+			Input:    "a === 1",
+			Code:     CodeInvalidValue,
+			Offset:   2,
+			Line:     1,
+			Column:   3,
+			Value:    "",
+			Expected: []TokenID{Match, Literal, Boolean, Duration, Timestamp, SemVer, IPAddress, CIDR},
+		},
+		{
+			Input:    "né == 1 || a =~ /[/",
+			Code:     CodeInvalidRegex,
+			Offset:   17,
+			Line:     1,
+			Column:   17,
+			Value:    "/[/",
+			Expected: []TokenID{Match},
+		},
+	}
+	for i, c := range cs {
+		_, err := New(c.Input).Parse()
+		if !assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input) {
+			continue
+		}
+		assert.True(t, errors.Is(err, ErrInvalidExpression), "case %d, should wrap ErrInvalidExpression", i)
+
+		var e *ParseError
+		if !assert.True(t, errors.As(err, &e), "case %d, expected a parse error", i) {
+			continue
+		}
+		assert.Equal(t, c.Code, e.Code, "case %d, expected code: %s, got: %s", i, c.Code, e.Code)
+		assert.Equal(t, c.Offset, e.Offset, "case %d, offset", i)
+		assert.Equal(t, c.Line, e.Line, "case %d, line", i)
+		assert.Equal(t, c.Column, e.Column, "case %d, column", i)
+		assert.Equal(t, c.Value, e.Token.Value, "case %d, token", i)
+		assert.Equal(t, c.Expected, e.Expected, "case %d, expected tokens", i)
+		assert.Equal(t, e.Message, e.Error())
+		assert.Regexp(t, fmt.Sprintf(`position: %d\b`, e.Offset), e.Message, "case %d, message position", i)
+	}
+}
+
+func TestErrorCodeString(t *testing.T) {
+	cs := []struct {
+		Code     ErrorCode
+		Expected string
+	}{
+		{Code: CodeUnexpectedToken, Expected: "unexpected token"},
+		{Code: CodeUnclosedGroup, Expected: "unclosed group"},
+		{Code: CodeInvalidString, Expected: "invalid string"},
+		{Code: CodeInvalidGlob, Expected: "invalid glob"},
+		{Code: CodeUnknownFunction, Expected: "unknown function"},
+		{Code: CodeInvalidArguments, Expected: "invalid arguments"},
+		{Code: ErrorCode(0), Expected: "unknown"},
+	}
+	for _, c := range cs {
+		assert.Equal(t, c.Expected, c.Code.String())
+	}
+}
+
+func TestExpectedTokens(t *testing.T) {
+	assert.Equal(t, []TokenID{Expr, OpenGroup, Quantifier, LogicalNot, Exists, Missing, ArithmeticNegate, FunctionCall}, expectedTokens(Entry))
+	assert.Equal(t, []TokenID{Match, OpenGroup, Literal, Boolean, Duration, Timestamp, IPAddress, CIDR, SemVer, ArithmeticNegate, FunctionCall}, expectedTokens(LogicalEqual))
+}
+
+func TestParseErrorRender(t *testing.T) {
+	cs := []struct {
+		Input    string
+		Expected string
+	}{
+		{
+			Input: "a == 1 &&\n  b => 1",
+			Expected: "line 2, column 5: '=' is missing a value at position: 14\n" +
+				"  b => 1\n" +
+				"    ^~\n" +
+				"did you mean '>='?",
+		},
+		{
+			Input: "test===9",
+			Expected: "line 1, column 5: '==' is missing a value at position: 4\n" +
+				"test===9\n" +
+				"    ^~~\n" +
+				"did you mean '=='?",
+		},
+		{
+			Input: "retries + 1 >= max_retries",
+			Expected: "line 1, column 16: value: max_retries at position: 15 must be numeric, a duration, a timestamp, a version or a selector named with a $ i.e. $max_retries when using less or greater than\n" +
+				"retries + 1 >= max_retries\n" +
+				"               ^~~~~~~~~~~",
+		},
+		{
+			Input: "a == 1 &&\n\tb >= xyz || c == 1",
+			Expected: "line 2, column 7: value: xyz at position: 16 must be numeric, a duration, a timestamp, a version or a selector named with a $ i.e. $xyz when using less or greater than\n" +
+				"\tb >= xyz || c == 1\n" +
+				"\t     ^~~",
+		},
+		{
+			Input: "a == 1 &&",
+			Expected: "line 1, column 10: 'END' found at position: 9 cannot follow '&&'\n" +
+				"a == 1 &&\n" +
+				"         ^",
+		},
+	}
+	for i, c := range cs {
+		_, err := New(c.Input).Parse()
+		var e *ParseError
+		if !assert.True(t, errors.As(err, &e), "case %d, expected a parse error", i) {
+			continue
+		}
+		assert.Equal(t, c.Expected, e.Render(), "case %d, input: %q", i, c.Input)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	cs := []struct {
+		Input    string
+		Offset   int
+		Expected string
+	}{
+		{Input: "a === 1", Offset: 4, Expected: "=="},
+		{Input: "a => 1", Offset: 3, Expected: ">="},
+		{Input: "a =< 1", Offset: 2, Expected: "<="},
+		{Input: "a <> 1", Offset: 3, Expected: "!="},
+		{Input: "a == 1 & b", Offset: 7, Expected: "&&"},
+		{Input: "a == 1 | b", Offset: 7, Expected: "||"},
+		{Input: "a == 1", Offset: 3, Expected: ""},
+		{Input: "a == 1", Offset: 5, Expected: ""},
+	}
+	for i, c := range cs {
+		hint, _, _ := suggest(c.Input, c.Offset)
+		assert.Equal(t, c.Expected, hint, "case %d, input: %s", i, c.Input)
+	}
+}
@@ -16,13 +16,266 @@ limitations under the License.
 
 package lex
 
-// Evaluate is responsible for evaluating the expression
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Evaluate is responsible for evaluating the expression against the input values. By
+// default the expression is true if any of the values satisfies the operation, with the
+// QuantifierAll every value must satisfy it; in either case no values never matches.
+// The values are coerced to the type of the match, i.e. numeric matches accept any integer
+// or float type and numeric strings, string matches accept strings, byte slices,
+// fmt.Stringers, booleans and numbers. A value which cannot be coerced never satisfies the
+// operation, apart from NE where it is by definition not equal. A nil value is absent, the
+// comparison is unknown and never satisfies the operation, NE included.
+//
+// A null match tests for the absence of the selector, which is null when it has no values
+// or only nil values; the quantifier does not apply.
+// A reference match returns ErrInvalidExpression, the selector it names is only resolved
+// by Group.Evaluate.
 func (e *Expression) Evaluate(input []interface{}) (bool, error) {
-	var matched bool
+	t, err := e.test(input)
+	if err != nil {
+		return false, err
+	}
+
+	return t == truthTrue, nil
+}
+
+// evaluate is responsible for resolving the selector and evaluating the expression, an
+// arithmetic expression or function call is resolved first and is unknown when it has no outcome
+func (e *Expression) evaluate(fn ValueFn) (truth, error) {
+	if v, found := e.Match.(*Value); found {
+		values, err := v.resolve(fn)
+		if err != nil {
+			return truthUnknown, err
+		}
+
+		return e.against(fn, values)
+	}
+	if name, found := e.Match.(Reference); found {
+		return e.dereference(fn, name)
+	}
+	values, err := e.resolve(fn)
+	if err != nil {
+		return truthFalse, err
+	}
+
+	return e.test(values)
+}
+
+// dereference is responsible for resolving the selector named by the match and comparing
+// against its value, a selector without values is unknown
+func (e *Expression) dereference(fn ValueFn, name Reference) (truth, error) {
+	values, err := fn(string(name))
+	if err != nil {
+		return truthFalse, err
+	}
+
+	return e.against(fn, values)
+}
+
+// against is responsible for comparing against the value resolved for the match, which must
+// be single, otherwise the comparison is unknown. The value is typed as a literal would be,
+// and an operation which does not apply to the type is false
+func (e *Expression) against(fn ValueFn, values []interface{}) (truth, error) {
+	if len(values) != 1 || values[0] == nil {
+		return truthUnknown, nil
+	}
+	match, found := toMatch(e.Operation, values[0])
+	if !found {
+		return truthFalse, nil
+	}
+	x := *e
+	x.Match = match
+	t, err := x.evaluate(fn)
+	if err == ErrInvalidExpressionEqaulity {
+		return truthFalse, nil
+	}
+
+	return t, err
+}
+
+// resolve is responsible for resolving the values of the selector, or the values of the
+// arithmetic expression or function call in its place
+func (e *Expression) resolve(fn ValueFn) ([]interface{}, error) {
+	if e.Value == nil {
+		return fn(e.Selector)
+	}
+
+	return e.Value.resolve(fn)
+}
+
+// test is responsible for evaluating the expression against the values with three valued
+// logic, a comparison against an absent value is unknown
+func (e *Expression) test(input []interface{}) (truth, error) {
+	if _, found := e.Match.(Null); found {
+		return e.testNull(input)
+	}
+	all := e.Quantifier == QuantifierAll
+	unknown := len(input) == 0
+	for _, x := range input {
+		if x == nil {
+			unknown = true
+			continue
+		}
+		matched, err := e.compare(x)
+		if err != nil {
+			return truthFalse, err
+		}
+		if matched != all {
+			return toTruth(matched), nil
+		}
+	}
+	if unknown {
+		return truthUnknown, nil
+	}
+
+	return toTruth(all), nil
+}
+
+// testNull is responsible for checking if the selector is null i.e. has no values
+func (e *Expression) testNull(input []interface{}) (truth, error) {
+	null := true
+	for _, x := range input {
+		if x != nil {
+			null = false
+			break
+		}
+	}
+	switch e.Operation {
+	case EQ:
+		return toTruth(null), nil
+	case NE:
+		return toTruth(!null), nil
+	}
+
+	return truthFalse, ErrInvalidExpressionEqaulity
+}
+
+// compare is responsible for applying the operation to a single value
+func (e *Expression) compare(value interface{}) (bool, error) {
+	switch e.Operation {
+	case TRUTHY:
+		return isTruthy(value), nil
+	case CUSTOM:
+		return e.Operator.apply(value, e.Match)
+	}
+	switch match := e.Match.(type) {
+	case bool:
+		v, found := toBool(value)
+		switch e.Operation {
+		case EQ:
+			return found && v == match, nil
+		case NE:
+			return !found || v != match, nil
+		}
+	case float64:
+		v, found := toFloat(value)
+		switch e.Operation {
+		case EQ:
+			return found && v == match, nil
+		case NE:
+			return !found || v != match, nil
+		case GT:
+			return found && v > match, nil
+		case GTE:
+			return found && v >= match, nil
+		case LT:
+			return found && v < match, nil
+		case LTE:
+			return found && v <= match, nil
+		}
+	case string:
+		v, found := toString(value)
+		switch e.Operation {
+		case EQ:
+			return found && v == match, nil
+		case NE:
+			return !found || v != match, nil
+		case PREFIX:
+			return found && strings.HasPrefix(v, match), nil
+		case SUFFIX:
+			return found && strings.HasSuffix(v, match), nil
+		case CONTAINS:
+			return found && strings.Contains(v, match), nil
+		case EQI:
+			return found && strings.EqualFold(v, match), nil
+		}
+	case time.Duration:
+		v, found := toDuration(value)
+		c := 0
+		if v < match {
+			c = -1
+		} else if v > match {
+			c = 1
+		}
+		return ordered(e.Operation, found, c)
+	case time.Time:
+		v, found := toTime(value)
+		return ordered(e.Operation, found, v.Compare(match))
+	case Version:
+		v, found := toVersion(value)
+		return ordered(e.Operation, found, v.Compare(match))
+	case netip.Addr:
+		v, found := toAddr(value)
+		return ordered(e.Operation, found, v.Compare(match))
+	case netip.Prefix:
+		switch e.Operation {
+		case IN:
+			v, found := toAddr(value)
+			return found && match.Contains(v), nil
+		case NOTIN:
+			v, found := toAddr(value)
+			return !found || !match.Contains(v), nil
+		case EQ:
+			v, found := toPrefix(value)
+			return found && v == match, nil
+		case NE:
+			v, found := toPrefix(value)
+			return !found || v != match, nil
+		}
+	case *regexp.Regexp:
+		v, found := toString(value)
+		if e.Operation == LIKE || e.Operation == GLOB {
+			return found && match.MatchString(v), nil
+		}
+	case *List:
+		switch e.Operation {
+		case IN:
+			return match.Contains(value), nil
+		case NOTIN:
+			return !match.Contains(value), nil
+		}
+	default:
+		return false, ErrInvalidExpression
+	}
+
+	return false, ErrInvalidExpressionEqaulity
+}
 
-	// step: first we need to convert the expression match
+// ordered is responsible for applying the operation to the outcome of comparing the value
+// to the match, which is negative when the value is less than the match
+func ordered(operation OperationID, found bool, c int) (bool, error) {
+	switch operation {
+	case EQ:
+		return found && c == 0, nil
+	case NE:
+		return !found || c != 0, nil
+	case GT:
+		return found && c > 0, nil
+	case GTE:
+		return found && c >= 0, nil
+	case LT:
+		return found && c < 0, nil
+	case LTE:
+		return found && c <= 0, nil
+	}
 
-	return matched, nil
+	return false, ErrInvalidExpressionEqaulity
 }
 
 // String returns a string representation of the expression
@@ -15,3 +15,133 @@ limitations under the License.
 */
 
 package lex
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stringer string
+
+func (s stringer) String() string {
+	return string(s)
+}
+
+func TestExpressionEvaluate(t *testing.T) {
+	cs := []struct {
+		Expression Expression
+		Input      []interface{}
+		Expected   bool
+	}{
+		{Expression: Expression{Operation: EQ, Match: 1.0}, Input: []interface{}{2}},
+		{Expression: Expression{Operation: EQ, Match: 1.0}, Input: []interface{}{1.0}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: 1.0}, Input: []interface{}{int64(1)}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: 1.0}, Input: []interface{}{uint8(1)}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: 1.0}, Input: []interface{}{"1"}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: 1.0}, Input: []interface{}{"one"}},
+		{Expression: Expression{Operation: EQ, Match: 1.0}, Input: []interface{}{2, 3, 1}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: 1.0}, Input: []interface{}{}},
+		{Expression: Expression{Operation: NE, Match: 1.0}, Input: []interface{}{2}, Expected: true},
+		{Expression: Expression{Operation: NE, Match: 1.0}, Input: []interface{}{"one"}, Expected: true},
+		{Expression: Expression{Operation: NE, Match: 1.0}, Input: []interface{}{1}},
+		{Expression: Expression{Operation: GT, Match: 5.0}, Input: []interface{}{6}, Expected: true},
+		{Expression: Expression{Operation: GT, Match: 5.0}, Input: []interface{}{5}},
+		{Expression: Expression{Operation: GT, Match: 5.0}, Input: []interface{}{"six"}},
+		{Expression: Expression{Operation: GTE, Match: 5.0}, Input: []interface{}{5}, Expected: true},
+		{Expression: Expression{Operation: LT, Match: 5.0}, Input: []interface{}{float32(4.5)}, Expected: true},
+		{Expression: Expression{Operation: LT, Match: 5.0}, Input: []interface{}{5}},
+		{Expression: Expression{Operation: LTE, Match: 5.0}, Input: []interface{}{"5"}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: "test"}, Input: []interface{}{"test"}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: "test"}, Input: []interface{}{[]byte("test")}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: "test"}, Input: []interface{}{stringer("test")}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: "test"}, Input: []interface{}{"tests"}},
+		{Expression: Expression{Operation: EQ, Match: "true"}, Input: []interface{}{true}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: "1.5"}, Input: []interface{}{1.5}, Expected: true},
+		{Expression: Expression{Operation: NE, Match: "test"}, Input: []interface{}{"tests"}, Expected: true},
+		{Expression: Expression{Operation: NE, Match: "test"}, Input: []interface{}{struct{}{}}, Expected: true},
+		{Expression: Expression{Operation: LIKE, Match: regexp.MustCompile("^te")}, Input: []interface{}{"test"}, Expected: true},
+		{Expression: Expression{Operation: LIKE, Match: regexp.MustCompile("^te")}, Input: []interface{}{"best"}},
+		{Expression: Expression{Operation: LIKE, Match: regexp.MustCompile("^1")}, Input: []interface{}{12}, Expected: true},
+		{Expression: Expression{Quantifier: QuantifierAll, Operation: LT, Match: 1024.0}, Input: []interface{}{22, 80, 443}, Expected: true},
+		{Expression: Expression{Quantifier: QuantifierAll, Operation: LT, Match: 1024.0}, Input: []interface{}{22, 8080, 443}},
+		{Expression: Expression{Quantifier: QuantifierAll, Operation: LT, Match: 1024.0}, Input: []interface{}{}},
+		{Expression: Expression{Quantifier: QuantifierAny, Operation: EQ, Match: "prod"}, Input: []interface{}{"dev", "prod"}, Expected: true},
+		{Expression: Expression{Operation: PREFIX, Match: "/api"}, Input: []interface{}{"/api/v1"}, Expected: true},
+		{Expression: Expression{Operation: PREFIX, Match: "/api"}, Input: []interface{}{"/v1/api"}},
+		{Expression: Expression{Operation: SUFFIX, Match: ".json"}, Input: []interface{}{stringer("a.json")}, Expected: true},
+		{Expression: Expression{Operation: SUFFIX, Match: ".json"}, Input: []interface{}{"a.yaml"}},
+		{Expression: Expression{Operation: CONTAINS, Match: "out"}, Input: []interface{}{"timeout"}, Expected: true},
+		{Expression: Expression{Operation: CONTAINS, Match: "out"}, Input: []interface{}{nil}},
+		{Expression: Expression{Operation: EQI, Match: "Bob"}, Input: []interface{}{"bOB"}, Expected: true},
+		{Expression: Expression{Operation: EQI, Match: "Bob"}, Input: []interface{}{"Bobby"}},
+		{Expression: Expression{Operation: GLOB, Match: regexp.MustCompile("^web-[^/]*$")}, Input: []interface{}{"web-01"}, Expected: true},
+		{Expression: Expression{Operation: NE, Match: 5.0}, Input: []interface{}{nil}},
+		{Expression: Expression{Operation: EQ, Match: 5.0}, Input: []interface{}{nil, 5}, Expected: true},
+		{Expression: Expression{Quantifier: QuantifierAll, Operation: EQ, Match: 5.0}, Input: []interface{}{nil, 5}},
+		{Expression: Expression{Operation: EQ, Match: Null{}}, Input: []interface{}{}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: Null{}}, Input: []interface{}{nil}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: Null{}}, Input: []interface{}{nil, 1}},
+		{Expression: Expression{Operation: NE, Match: Null{}}, Input: []interface{}{""}, Expected: true},
+		{Expression: Expression{Operation: NE, Match: Null{}}, Input: []interface{}{}},
+		{Expression: Expression{Operation: EQ, Match: true}, Input: []interface{}{true}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: true}, Input: []interface{}{"TRUE"}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: false}, Input: []interface{}{1}},
+		{Expression: Expression{Operation: NE, Match: false}, Input: []interface{}{1}, Expected: true},
+		{Expression: Expression{Operation: TRUTHY}, Input: []interface{}{"yes"}, Expected: true},
+		{Expression: Expression{Operation: TRUTHY}, Input: []interface{}{0.0}},
+		{Expression: Expression{Quantifier: QuantifierAll, Operation: TRUTHY}, Input: []interface{}{true, 1}, Expected: true},
+		{Expression: Expression{Operation: GT, Match: time.Minute}, Input: []interface{}{time.Hour}, Expected: true},
+		{Expression: Expression{Operation: LTE, Match: time.Minute}, Input: []interface{}{"60s"}, Expected: true},
+		{Expression: Expression{Operation: LT, Match: time.Minute}, Input: []interface{}{60}},
+		{Expression: Expression{Operation: NE, Match: time.Minute}, Input: []interface{}{"soon"}, Expected: true},
+		{Expression: Expression{Operation: GTE, Match: time.Unix(0, 0)}, Input: []interface{}{time.Unix(1, 0)}, Expected: true},
+		{Expression: Expression{Operation: LT, Match: time.Unix(0, 0)}, Input: []interface{}{"1970-01-01T00:00:00Z"}},
+		{Expression: Expression{Operation: EQ, Match: time.Unix(0, 0)}, Input: []interface{}{"1970-01-01T01:00:00+01:00"}, Expected: true},
+		{Expression: Expression{Operation: GTE, Match: Version{Major: 1, Minor: 12}}, Input: []interface{}{"v1.12.0"}, Expected: true},
+		{Expression: Expression{Operation: LT, Match: Version{Major: 1}}, Input: []interface{}{Version{Major: 1, Prerelease: []string{"rc", "1"}}}, Expected: true},
+		{Expression: Expression{Operation: NE, Match: Version{Major: 1}}, Input: []interface{}{"test"}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: netip.MustParseAddr("10.0.0.1")}, Input: []interface{}{net.IPv4(10, 0, 0, 1)}, Expected: true},
+		{Expression: Expression{Operation: GT, Match: netip.MustParseAddr("10.0.0.1")}, Input: []interface{}{"10.0.0.2"}, Expected: true},
+		{Expression: Expression{Operation: IN, Match: netip.MustParsePrefix("10.0.0.0/8")}, Input: []interface{}{"10.0.0.2"}, Expected: true},
+		{Expression: Expression{Operation: IN, Match: netip.MustParsePrefix("10.0.0.0/8")}, Input: []interface{}{"11.0.0.2"}},
+		{Expression: Expression{Operation: NOTIN, Match: netip.MustParsePrefix("10.0.0.0/8")}, Input: []interface{}{"test"}, Expected: true},
+		{Expression: Expression{Operation: EQ, Match: netip.MustParsePrefix("10.0.0.0/8")}, Input: []interface{}{"10.1.0.0/8"}, Expected: true},
+		{Expression: Expression{Operation: NE, Match: netip.MustParsePrefix("10.0.0.0/8")}, Input: []interface{}{"10.0.0.0/16"}, Expected: true},
+	}
+	for i, c := range cs {
+		matched, err := c.Expression.Evaluate(c.Input)
+		assert.NoError(t, err, "case %d, should not have returned an error", i)
+		assert.Equal(t, c.Expected, matched, "case %d, expected: %t, got: %t", i, c.Expected, matched)
+	}
+}
+
+func TestExpressionEvaluateBad(t *testing.T) {
+	cs := []struct {
+		Expression Expression
+		Expected   error
+	}{
+		{Expression: Expression{Operation: GT, Match: "test"}, Expected: ErrInvalidExpressionEqaulity},
+		{Expression: Expression{Operation: LIKE, Match: "test"}, Expected: ErrInvalidExpressionEqaulity},
+		{Expression: Expression{Operation: EQ, Match: regexp.MustCompile("test")}, Expected: ErrInvalidExpressionEqaulity},
+		{Expression: Expression{Operation: NA, Match: 1.0}, Expected: ErrInvalidExpressionEqaulity},
+		{Expression: Expression{Operation: EQ, Match: newList()}, Expected: ErrInvalidExpressionEqaulity},
+		{Expression: Expression{Operation: IN, Match: "test"}, Expected: ErrInvalidExpressionEqaulity},
+		{Expression: Expression{Operation: GT, Match: Null{}}, Expected: ErrInvalidExpressionEqaulity},
+		{Expression: Expression{Operation: LIKE, Match: time.Minute}, Expected: ErrInvalidExpressionEqaulity},
+		{Expression: Expression{Operation: GT, Match: netip.MustParsePrefix("10.0.0.0/8")}, Expected: ErrInvalidExpressionEqaulity},
+		{Expression: Expression{Operation: LIKE, Match: Version{Major: 1}}, Expected: ErrInvalidExpressionEqaulity},
+		{Expression: Expression{Operation: EQ}, Expected: ErrInvalidExpression},
+		{Expression: Expression{Operation: EQ, Match: Reference("test")}, Expected: ErrInvalidExpression},
+	}
+	for i, c := range cs {
+		matched, err := c.Expression.Evaluate([]interface{}{"test"})
+		assert.False(t, matched, "case %d, should not have matched", i)
+		assert.True(t, errors.Is(err, c.Expected), "case %d, expected: %v, got: %v", i, c.Expected, err)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// ValueTypeAny indicates the values can be of any type
+	ValueTypeAny ValueType = 0
+	// ValueTypeString indicates the values are strings
+	ValueTypeString ValueType = 1
+	// ValueTypeNumber indicates the values are numbers, which are passed as float64
+	ValueTypeNumber ValueType = 2
+	// ValueTypeBoolean indicates the values are booleans
+	ValueTypeBoolean ValueType = 3
+)
+
+// builtins are the functions which can be called in any expression
+var builtins = map[string]*Function{
+	"abs":   {Parameters: []ValueType{ValueTypeNumber}, Returns: ValueTypeNumber, Call: eachFloat(math.Abs)},
+	"len":   {Parameters: []ValueType{ValueTypeAny}, Returns: ValueTypeNumber, Call: length},
+	"lower": {Parameters: []ValueType{ValueTypeString}, Returns: ValueTypeString, Call: eachString(strings.ToLower)},
+	"trim":  {Parameters: []ValueType{ValueTypeString}, Returns: ValueTypeString, Call: eachString(strings.TrimSpace)},
+	"upper": {Parameters: []ValueType{ValueTypeString}, Returns: ValueTypeString, Call: eachString(strings.ToUpper)},
+}
+
+// reserved are the words which open a bracket and cannot be used as the name of a function
+var reserved = map[string]bool{"all": true, "any": true, "exists": true, "in": true, "missing": true}
+
+// String returns a string representation of the value type
+func (t *ValueType) String() string {
+	switch *t {
+	case ValueTypeAny:
+		return "any"
+	case ValueTypeString:
+		return "string"
+	case ValueTypeNumber:
+		return "number"
+	case ValueTypeBoolean:
+		return "boolean"
+	}
+
+	return "unknown"
+}
+
+// accepts checks if a value of the type can be used where the type is expected, a value of
+// any type is checked when it is evaluated
+func (t ValueType) accepts(x ValueType) bool {
+	return t == ValueTypeAny || x == ValueTypeAny || t == x
+}
+
+// convert is responsible for converting the values to the type, a value which cannot be
+// converted is dropped and a nil value is kept as absent
+func (t ValueType) convert(values []interface{}) []interface{} {
+	if t == ValueTypeAny {
+		return values
+	}
+	var list []interface{}
+	for _, x := range values {
+		if x == nil {
+			list = append(list, nil)
+			continue
+		}
+		var v interface{}
+		var found bool
+		switch t {
+		case ValueTypeString:
+			v, found = toString(x)
+		case ValueTypeNumber:
+			v, found = toFloat(x)
+		case ValueTypeBoolean:
+			v, found = toBool(x)
+		}
+		if found {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+// NewFunctionRegistry creates an empty registry of functions
+func NewFunctionRegistry() *FunctionRegistry {
+	return &FunctionRegistry{functions: make(map[string]*Function)}
+}
+
+// WithFunctions permits the functions in the registry to be called in the expression, along
+// with the built-in functions
+func WithFunctions(r *FunctionRegistry) Option {
+	return func(l *Lexer) {
+		l.functions = r
+	}
+}
+
+// Register adds the function to the registry, the name must be a word which is not a built-in
+// function i.e. geo_distance
+func (r *FunctionRegistry) Register(name string, fn Function) error {
+	switch {
+	case !functionName.MatchString(name) || reserved[name]:
+		return fmt.Errorf("function: %s is not a valid name", name)
+	case builtins[name] != nil:
+		return fmt.Errorf("function: %s is a built-in function", name)
+	case fn.Call == nil:
+		return fmt.Errorf("function: %s has no call", name)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, found := r.functions[name]; found {
+		return fmt.Errorf("function: %s is already registered", name)
+	}
+	r.functions[name] = &fn
+
+	return nil
+}
+
+// lookup returns the function registered under the name
+func (r *FunctionRegistry) lookup(name string) (*Function, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	fn, found := r.functions[name]
+
+	return fn, found
+}
+
+// function returns the function which can be called under the name in the expression
+func (l *Lexer) function(name string) (*Function, bool) {
+	if fn, found := builtins[name]; found {
+		return fn, true
+	}
+	if l.functions == nil {
+		return nil, false
+	}
+
+	return l.functions.lookup(name)
+}
+
+// length returns the number of values of the argument, which is zero when it has none
+func length(arguments [][]interface{}) ([]interface{}, error) {
+	var count float64
+	for _, x := range arguments[0] {
+		if x != nil {
+			count++
+		}
+	}
+
+	return []interface{}{count}, nil
+}
+
+// eachString returns a function which applies the method to the string values of the argument
+func eachString(method func(string) string) func([][]interface{}) ([]interface{}, error) {
+	return func(arguments [][]interface{}) ([]interface{}, error) {
+		list := make([]interface{}, len(arguments[0]))
+		for i, x := range arguments[0] {
+			if x != nil {
+				list[i] = method(x.(string))
+			}
+		}
+
+		return list, nil
+	}
+}
+
+// eachFloat returns a function which applies the method to the numeric values of the argument
+func eachFloat(method func(float64) float64) func([][]interface{}) ([]interface{}, error) {
+	return func(arguments [][]interface{}) ([]interface{}, error) {
+		list := make([]interface{}, len(arguments[0]))
+		for i, x := range arguments[0] {
+			if x != nil {
+				list[i] = method(x.(float64))
+			}
+		}
+
+		return list, nil
+	}
+}
+
+// typeOf returns the type of the value, which for a selector is only known when evaluated
+func (v *Value) typeOf() ValueType {
+	switch {
+	case v.Arithmetic != ArithmeticTypeNone:
+		return ValueTypeNumber
+	case v.Call != nil:
+		return v.Call.function.Returns
+	case v.Literal != nil:
+		return ValueTypeString
+	case v.Selector != "":
+		return ValueTypeAny
+	}
+
+	return ValueTypeNumber
+}
+
+// evaluate is responsible for resolving the values of the arguments, converted to the type
+// of each parameter, and calling the function. The values returned are converted to the type
+// the function returns
+func (c *Call) evaluate(fn ValueFn) ([]interface{}, error) {
+	arguments := make([][]interface{}, len(c.Arguments))
+	for i, x := range c.Arguments {
+		values, err := x.resolve(fn)
+		if err != nil {
+			return nil, err
+		}
+		arguments[i] = c.function.Parameters[i].convert(values)
+	}
+	values, err := c.function.Call(arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.function.Returns.convert(values), nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltins(t *testing.T) {
+	cs := []struct {
+		Name     string
+		Values   []interface{}
+		Expected []interface{}
+	}{
+		{Name: "len", Values: []interface{}{"a", "b", nil}, Expected: []interface{}{float64(2)}},
+		{Name: "len", Expected: []interface{}{float64(0)}},
+		{Name: "lower", Values: []interface{}{"ADMIN", []byte("Bob"), nil}, Expected: []interface{}{"admin", "bob", nil}},
+		{Name: "upper", Values: []interface{}{"admin", true, struct{}{}}, Expected: []interface{}{"ADMIN", "TRUE"}},
+		{Name: "trim", Values: []interface{}{"  a b \t"}, Expected: []interface{}{"a b"}},
+		{Name: "abs", Values: []interface{}{-4, "2.5", "x", nil}, Expected: []interface{}{float64(4), float64(2.5), nil}},
+		{Name: "abs"},
+	}
+	for i, c := range cs {
+		fn := builtins[c.Name]
+		values, err := fn.Call([][]interface{}{fn.Parameters[0].convert(c.Values)})
+		assert.NoError(t, err, "case %d", i)
+		assert.Equal(t, c.Expected, fn.Returns.convert(values), "case %d, function: %s", i, c.Name)
+	}
+}
+
+func TestCallEvaluate(t *testing.T) {
+	fn := func(selector string) ([]interface{}, error) {
+		switch selector {
+		case "error":
+			return nil, errors.New("failed")
+		case "delta":
+			return []interface{}{-3}, nil
+		}
+		return []interface{}{" Name "}, nil
+	}
+	call := &Call{Name: "lower", Arguments: []*Value{{Call: &Call{Name: "trim", Arguments: []*Value{{Selector: "name"}}, function: builtins["trim"]}}}, function: builtins["lower"]}
+	values, err := call.evaluate(fn)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"name"}, values)
+
+	call = &Call{Name: "abs", Arguments: []*Value{{Arithmetic: ArithmeticTypeMultiply, Left: &Value{Selector: "delta"}, Right: &Value{Number: 2}}}, function: builtins["abs"]}
+	values, err = call.evaluate(fn)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{float64(6)}, values)
+
+	call = &Call{Name: "len", Arguments: []*Value{{Selector: "error"}}, function: builtins["len"]}
+	_, err = call.evaluate(fn)
+	assert.Error(t, err)
+}
+
+func TestValueTypeString(t *testing.T) {
+	cs := []struct {
+		Type     ValueType
+		Expected string
+	}{
+		{Type: ValueTypeAny, Expected: "any"},
+		{Type: ValueTypeString, Expected: "string"},
+		{Type: ValueTypeNumber, Expected: "number"},
+		{Type: ValueTypeBoolean, Expected: "boolean"},
+		{Type: ValueType(-1), Expected: "unknown"},
+	}
+	for _, c := range cs {
+		assert.Equal(t, c.Expected, c.Type.String())
+	}
+}
+
+func TestValueTypeConvert(t *testing.T) {
+	values := []interface{}{"1", 2, "true", nil, struct{}{}}
+	assert.Equal(t, values, ValueTypeAny.convert(values))
+	assert.Equal(t, []interface{}{"1", "2", "true", nil}, ValueTypeString.convert(values))
+	assert.Equal(t, []interface{}{float64(1), float64(2), nil}, ValueTypeNumber.convert(values))
+	assert.Equal(t, []interface{}{true, nil}, ValueTypeBoolean.convert(values))
+}
+
+func TestFunctionRegistry(t *testing.T) {
+	call := func([][]interface{}) ([]interface{}, error) {
+		return nil, nil
+	}
+	r := NewFunctionRegistry()
+	assert.NoError(t, r.Register("has_role", Function{Parameters: []ValueType{ValueTypeAny, ValueTypeString}, Returns: ValueTypeBoolean, Call: call}))
+	assert.Error(t, r.Register("has_role", Function{Call: call}))
+	assert.Error(t, r.Register("lower", Function{Call: call}))
+	assert.Error(t, r.Register("any", Function{Call: call}))
+	assert.Error(t, r.Register("has.role", Function{Call: call}))
+	assert.Error(t, r.Register("1role", Function{Call: call}))
+	assert.Error(t, r.Register("no_call", Function{}))
+
+	fn, found := r.lookup("has_role")
+	assert.True(t, found)
+	assert.Equal(t, ValueTypeBoolean, fn.Returns)
+	_, found = r.lookup("no_call")
+	assert.False(t, found)
+}
@@ -0,0 +1,13 @@
+module github.com/gambol99/go-lexer
+
+go 1.20
+
+require (
+	github.com/davecgh/go-spew v1.1.1
+	github.com/stretchr/testify v1.8.4
+)
+
+require (
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,1009 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"io"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// lowestPrecedence is the precedence the parsing of an expression starts at
+const lowestPrecedence = 1
+
+var (
+	// logicalPrecedence is the binding power of the logical operators, the higher binds tighter
+	logicalPrecedence = map[TokenID]int{
+		LogicalOr:  1,
+		LogicalAnd: 2,
+	}
+	// arithmeticPrecedence is the binding power of the arithmetic operators, the higher binds tighter
+	arithmeticPrecedence = map[TokenID]int{
+		ArithmeticAdd:      1,
+		ArithmeticSubtract: 1,
+		ArithmeticMultiply: 2,
+		ArithmeticDivide:   2,
+		ArithmeticModulo:   2,
+	}
+)
+
+// parser is a precedence climbing parser over the token stream
+type parser struct {
+	// the lexer we are parsing for
+	lexer *Lexer
+	// the source of the tokens
+	tokens *tokenizer
+	// the current token
+	token Token
+	// indicates we record the errors and resynchronise rather than stopping
+	recovering bool
+	// indicates we are parsing the right hand side of an operation, where a bare word is a
+	// string and a selector is named with a $ i.e. $start_time
+	matching bool
+	// the errors found while recovering
+	errors ParseErrors
+}
+
+// newParser creates a parser for the lexer input
+func newParser(l *Lexer, recovering bool) *parser {
+	return &parser{
+		lexer:      l,
+		tokens:     newTokenizer(l.input, l.operators.sorted()),
+		recovering: recovering,
+	}
+}
+
+// parse is responsible for parsing the token stream into a tree
+func (p *parser) parse() (*Group, error) {
+	// step: move past the entry token
+	for i := 0; i < 2; i++ {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+	}
+	root, err := p.parseLogical(lowestPrecedence)
+	if err != nil {
+		return nil, err
+	}
+	for p.token.ID != EOF {
+		var err error
+		switch p.token.ID {
+		case CloseGroup:
+			err = newParseError(CodeUnopenedGroup, p.token, []TokenID{EOF, LogicalAnd, LogicalOr},
+				"')' closed as position: %d was not opened", p.token.Start)
+		default:
+			err = newParseError(CodeUnexpectedToken, p.token, []TokenID{EOF, LogicalAnd, LogicalOr},
+				"'%s' found at position: %d was unexpected", p.token.Value, p.token.Start)
+		}
+		if !p.recovering {
+			return nil, err
+		}
+		// step: skip the token and carry on from the next logical operation
+		p.fail(err)
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if err := p.synchronise(); err != nil {
+			return nil, err
+		}
+		if root, err = p.parseOperations(root, lowestPrecedence); err != nil {
+			return nil, err
+		}
+	}
+
+	return root, nil
+}
+
+// next is responsible for moving onto the next token, checking it against the ruleset. The
+// parser moves onto the token even if it breaks the ruleset, so we can recover from it
+func (p *parser) next() error {
+	token, err := p.tokens.Next()
+	if err == io.EOF {
+		return newParseError(CodeUnexpectedEnd, Token{ID: EOF, Start: p.token.End, End: p.token.End}, nil,
+			"unexpected end of input at position: %d", p.token.End)
+	}
+	// emit the token to any listeners
+	p.lexer.emitTokenListener(token)
+	// if we have a previous token check against the ruleset
+	last := p.token
+	p.token = token
+	switch {
+	case token.ID == Unknown && token.Value == "":
+		return newParseError(CodeUnexpectedEnd, token, []TokenID{CloseGroup},
+			"input ended at position: %d, expected ')'", token.Start)
+	case token.ID == Unknown:
+		return newParseError(CodeUnexpectedToken, token, expectedTokens(last.ID),
+			"'%s' found at position: %d is not a valid operation", token.Value, token.Start)
+	}
+	if last.ID != Unknown {
+		if !validateTokenRules(last.ID, parsingRules[token.ID]) {
+			return newParseError(CodeUnexpectedToken, token, expectedTokens(last.ID),
+				"'%s' found at position: %d cannot follow '%s'", describe(token), token.Start, describe(last))
+		}
+	}
+
+	return nil
+}
+
+// skip is responsible for moving onto the next token, when recovering an error is
+// recorded and left for the grammar to resynchronise from
+func (p *parser) skip() error {
+	err := p.next()
+	if err != nil && p.recovering {
+		p.fail(err)
+		return nil
+	}
+
+	return err
+}
+
+// fail is responsible for recording an error while recovering, only the first error for
+// a token is kept
+func (p *parser) fail(err error) {
+	e, ok := err.(*ParseError)
+	if !ok {
+		e = newParseError(CodeUnexpectedToken, p.token, nil, "%s", err)
+	}
+	for _, x := range p.errors {
+		if x.Token.Start == e.Token.Start {
+			return
+		}
+	}
+	p.errors = append(p.errors, e)
+}
+
+// synchronise is responsible for skipping the tokens up to the next '&&', '||', ')' or the end
+func (p *parser) synchronise() error {
+	for {
+		switch p.token.ID {
+		case LogicalAnd, LogicalOr, CloseGroup, EOF:
+			return nil
+		}
+		if err := p.skip(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseLogical is responsible for parsing the logical operations which bind at least as
+// tight as the precedence
+func (p *parser) parseLogical(precedence int) (*Group, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parseOperations(left, precedence)
+}
+
+// parseOperations is responsible for parsing the logical operations following the left
+// hand side which bind at least as tight as the precedence
+func (p *parser) parseOperations(left *Group, precedence int) (*Group, error) {
+	for {
+		id := p.token.ID
+		binding, found := logicalPrecedence[id]
+		if !found || binding < precedence {
+			return left, nil
+		}
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseLogical(binding + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &Group{Logic: getLogic(id), Left: left, Right: right}
+	}
+}
+
+// parsePrimary is responsible for parsing an operand of the logical operations, when
+// recovering an error is recorded and the operand replaced with an empty group
+func (p *parser) parsePrimary() (*Group, error) {
+	group, err := p.parseOperand()
+	if err == nil || !p.recovering {
+		return group, err
+	}
+	p.fail(err)
+	if err := p.synchronise(); err != nil {
+		return nil, err
+	}
+
+	return new(Group), nil
+}
+
+// parseOperand is responsible for parsing a negation, a parenthesised group or an expression
+func (p *parser) parseOperand() (*Group, error) {
+	if p.token.ID == LogicalNot {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		group, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Group{Logic: LogicalTypeNot, Left: group}, nil
+	}
+	if p.token.ID != OpenGroup {
+		return p.parseExpression()
+	}
+	opened := p.token
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	group, err := p.parseLogical(lowestPrecedence)
+	if err != nil {
+		return nil, err
+	}
+	if p.token.ID != CloseGroup {
+		err := newParseError(CodeUnclosedGroup, opened, []TokenID{CloseGroup},
+			"'(' opened at position: %d was not closed", opened.Start)
+		if !p.recovering {
+			return nil, err
+		}
+		// step: we can keep the group as though it was closed
+		p.fail(err)
+
+		return group, nil
+	}
+	closed := p.token
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	// step: a group followed by an arithmetic or comparison operation is part of a value
+	if _, found := arithmeticPrecedence[p.token.ID]; found || isComparison(p.token.ID) || p.token.ID == LogicalCustom {
+		return p.parseParenthesised(group, closed)
+	}
+
+	return group, nil
+}
+
+// parseExpression is responsible for parsing the selector, operation and match
+func (p *parser) parseExpression() (*Group, error) {
+	if p.token.ID == Exists || p.token.ID == Missing {
+		return p.parsePresence()
+	}
+	e := new(Expression)
+	if p.token.ID == ArithmeticNegate || p.token.ID == FunctionCall {
+		v, err := p.parseValue(lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		e.Value = v
+
+		return p.parseOperation(e, Token{})
+	}
+	quantified := p.token.ID == Quantifier
+	if quantified {
+		e.Quantifier = getQuantifier(p.token.Value)
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if p.token.ID != Expr {
+		return nil, newParseError(CodeUnexpectedToken, p.token, []TokenID{Expr},
+			"'%s' found at position: %d, expected a selector", p.token.Value, p.token.Start)
+	}
+	selector := p.token
+	e.Selector = selector.Value
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	// step: check if the selector opens an arithmetic expression i.e. used / total > 0.5
+	if _, found := arithmeticPrecedence[p.token.ID]; !quantified && (found || isNegated(e.Selector)) {
+		left, err := p.valueOf(selector)
+		if err != nil {
+			return nil, err
+		}
+		v, err := p.parseArithmetic(left, lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		e.Selector, e.Value = "", v
+	}
+
+	return p.parseOperation(e, selector)
+}
+
+// parseOperation is responsible for parsing the operation and match of the expression
+func (p *parser) parseOperation(e *Expression, selector Token) (*Group, error) {
+	operation := p.token
+	switch operation.ID {
+	case LogicalAnd, LogicalOr, CloseGroup, EOF:
+		// step: a bare selector is a test of its truthiness
+		if e.Value == nil && !bareSelector.MatchString(e.Selector) {
+			return nil, newParseError(CodeUnexpectedToken, selector, expectedTokens(Expr),
+				"selector: '%s' found at position: %d is invalid, expected an operation", e.Selector, selector.Start)
+		}
+		e.Operation = TRUTHY
+
+		return &Group{Expression: e}, nil
+	}
+	if e.Operation = getOperation(operation.ID); e.Operation == NA {
+		return nil, newParseError(CodeUnexpectedToken, p.token, expectedTokens(Expr),
+			"'%s' found at position: %d, expected an operation", p.token.Value, p.token.Start)
+	}
+	if err := checkOrdered(operation, e.Value); err != nil {
+		return nil, err
+	}
+	if operation.ID == LogicalCustom {
+		if err := p.parseOperator(e, operation); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	p.matching = true
+	defer func() { p.matching = false }()
+
+	if (operation.ID == LogicalIn || operation.ID == LogicalNotIn) && p.token.ID != CIDR {
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		e.Match = list
+
+		return &Group{Expression: e}, nil
+	}
+	// step: a bracket or a negation opens an arithmetic expression i.e. x > -(y - 1), or
+	// the match is a function call i.e. name == lower(other)
+	if p.token.ID == OpenGroup || p.token.ID == ArithmeticNegate || p.token.ID == FunctionCall {
+		v, err := p.parseValue(lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+
+		return p.parseComputed(e, operation, v)
+	}
+	switch p.token.ID {
+	case Match, Literal, Boolean, Duration, Timestamp, SemVer, IPAddress, CIDR:
+	default:
+		return nil, newParseError(CodeUnexpectedToken, p.token, []TokenID{Match, Literal, Boolean, Duration, Timestamp, SemVer, IPAddress, CIDR},
+			"'%s' found at position: %d, expected a value", describe(p.token), p.token.Start)
+	}
+	// step: the match is empty when the operation is followed by another or the end i.e. a == )
+	if p.token.Value == "" {
+		return nil, newParseError(CodeInvalidValue, p.token, []TokenID{Match, Literal, Boolean, Duration, Timestamp, SemVer, IPAddress, CIDR},
+			"'%s' is missing a value at position: %d", operation.Value, p.token.Start)
+	}
+	value := p.token
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if _, found := arithmeticPrecedence[p.token.ID]; found {
+		left, err := p.valueOf(value)
+		if err != nil {
+			return nil, err
+		}
+		v, err := p.parseArithmetic(left, lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+
+		return p.parseComputed(e, operation, v)
+	}
+	if isReference(operation.ID, value) {
+		e.Match = Reference(value.Value[1:])
+
+		return &Group{Expression: e}, nil
+	}
+	if e.Operator != nil {
+		match, err := parseOperatorMatch(e.Operator, value)
+		if err != nil {
+			return nil, err
+		}
+		e.Match = match
+
+		return &Group{Expression: e}, nil
+	}
+	match, err := parseMatch(operation.ID, value)
+	if err != nil {
+		return nil, err
+	}
+	e.Match = match
+
+	return &Group{Expression: e}, nil
+}
+
+// parseComputed is responsible for checking the operation can be used with the arithmetic
+// expression or function call being matched, which is a number when it was folded at parse time
+func (p *parser) parseComputed(e *Expression, operation Token, v *Value) (*Group, error) {
+	if e.Operator != nil {
+		if given := v.typeOf(); !e.Operator.Right.accepts(given) {
+			return nil, newParseError(CodeInvalidValue, operation, nil,
+				"'%s' found at position: %d cannot be used with a %s value", operation.Value, operation.Start, given.String())
+		}
+		e.Match = v
+		if v.isConstant() {
+			e.Match = v.Number
+		}
+		return &Group{Expression: e}, nil
+	}
+	if v.Call != nil && v.Arithmetic == ArithmeticTypeNone {
+		switch operation.ID {
+		case LogicalStartsWith, LogicalEndsWith, LogicalContains, LogicalEqualFold:
+			e.Match = v
+			return &Group{Expression: e}, nil
+		}
+	}
+	if !isComparison(operation.ID) {
+		return nil, newParseError(CodeUnexpectedToken, operation, []TokenID{LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual},
+			"'%s' found at position: %d cannot be used with an arithmetic expression", operation.Value, operation.Start)
+	}
+	if err := checkOrdered(operation, v); err != nil {
+		return nil, err
+	}
+	e.Match = v
+	if v.isConstant() {
+		e.Match = v.Number
+	}
+
+	return &Group{Expression: e}, nil
+}
+
+// parseOperator is responsible for resolving the registered operation and checking it can
+// be used with the selector
+func (p *parser) parseOperator(e *Expression, operation Token) error {
+	op, found := p.lexer.operators.lookup(operation.Value)
+	if !found {
+		return newParseError(CodeUnexpectedToken, operation, nil,
+			"'%s' found at position: %d is not a registered operation", operation.Value, operation.Start)
+	}
+	if e.Value != nil {
+		if given := e.Value.typeOf(); !op.Left.accepts(given) {
+			return newParseError(CodeInvalidValue, operation, nil,
+				"'%s' found at position: %d cannot be used with a %s value", operation.Value, operation.Start, given.String())
+		}
+	}
+	e.Operator = op
+
+	return nil
+}
+
+// parseParenthesised is responsible for parsing the remainder of an arithmetic expression
+// which opened with a group, the group must be a value i.e. (used + free) / total > 0.5
+func (p *parser) parseParenthesised(group *Group, closed Token) (*Group, error) {
+	e := group.Expression
+	if group.Logic != LogicalTypeNone || e == nil || e.Operation != TRUTHY || e.Quantifier != QuantifierAny {
+		return nil, newParseError(CodeUnexpectedToken, p.token, []TokenID{EOF, CloseGroup, LogicalAnd, LogicalOr},
+			"'%s' found at position: %d cannot follow '%s'", describe(p.token), p.token.Start, describe(closed))
+	}
+	left := e.Value
+	if left == nil {
+		left = &Value{Selector: e.Selector}
+	}
+	v, err := p.parseArithmetic(left, lowestPrecedence)
+	if err != nil {
+		return nil, err
+	}
+	e = new(Expression)
+	// step: a lone selector keeps its values i.e. (x) > 1
+	if v.Arithmetic == ArithmeticTypeNone && v.Selector != "" {
+		e.Selector = v.Selector
+	} else {
+		e.Value = v
+	}
+
+	return p.parseOperation(e, Token{})
+}
+
+// parseValue is responsible for parsing the arithmetic operations which bind at least as
+// tight as the precedence
+func (p *parser) parseValue(precedence int) (*Value, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parseArithmetic(left, precedence)
+}
+
+// parseArithmetic is responsible for parsing the arithmetic operations following the left
+// hand side which bind at least as tight as the precedence
+func (p *parser) parseArithmetic(left *Value, precedence int) (*Value, error) {
+	for {
+		operation := p.token
+		binding, found := arithmeticPrecedence[operation.ID]
+		if !found || binding < precedence {
+			return left, nil
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseValue(binding + 1)
+		if err != nil {
+			return nil, err
+		}
+		if left, err = compute(operation, left, right); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseFactor is responsible for parsing an operand of the arithmetic operations, either a
+// negation, a parenthesised value, a function call, a number or a selector
+func (p *parser) parseFactor() (*Value, error) {
+	switch p.token.ID {
+	case FunctionCall:
+		return p.parseCall()
+	case ArithmeticNegate:
+		operation := p.token
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		v, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+
+		return compute(operation, v, nil)
+	case OpenGroup:
+		opened := p.token
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue(lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		if p.token.ID != CloseGroup {
+			return nil, newParseError(CodeUnclosedGroup, opened, []TokenID{CloseGroup},
+				"'(' opened at position: %d was not closed", opened.Start)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		return v, nil
+	}
+	v, err := p.valueOf(p.token)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// parseCall is responsible for parsing the arguments of a function call and checking the
+// function is called with the number and type of arguments it takes i.e. lower(user.name)
+func (p *parser) parseCall() (*Value, error) {
+	name := p.token
+	found, ok := p.lexer.function(name.Value)
+	if !ok {
+		return nil, newParseError(CodeUnknownFunction, name, nil,
+			"function: %s() found at position: %d is not defined", name.Value, name.Start)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	call := &Call{Name: name.Value, function: found}
+	for p.token.ID != CloseGroup {
+		if len(call.Arguments) > 0 {
+			if p.token.ID != Separator {
+				return nil, newParseError(CodeUnclosedGroup, name, []TokenID{CloseGroup},
+					"function: %s() opened at position: %d was not closed", name.Value, name.Start)
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		argument := p.token
+		v, err := p.parseArgument()
+		if err != nil {
+			return nil, err
+		}
+		if i := len(call.Arguments); i < len(found.Parameters) && !found.Parameters[i].accepts(v.typeOf()) {
+			expected, given := found.Parameters[i], v.typeOf()
+			return nil, newParseError(CodeInvalidArguments, argument, nil,
+				"argument: %s at position: %d of %s() must be a %s, found a %s", describe(argument), argument.Start, name.Value, expected.String(), given.String())
+		}
+		call.Arguments = append(call.Arguments, v)
+	}
+	if len(call.Arguments) != len(found.Parameters) {
+		return nil, newParseError(CodeInvalidArguments, name, nil,
+			"function: %s() found at position: %d takes %d argument(s), found %d", name.Value, name.Start, len(found.Parameters), len(call.Arguments))
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	return &Value{Call: call}, nil
+}
+
+// parseArgument is responsible for parsing an argument of a function call, either a string
+// or a value. On the right hand side of an operation a bare word is a string as it would be
+// compared i.e. name == lower(Admin)
+func (p *parser) parseArgument() (*Value, error) {
+	var v interface{}
+	switch {
+	case p.token.ID == Literal:
+		literal, err := parseLiteral(LogicalEqual, p.token)
+		if err != nil {
+			return nil, err
+		}
+		v = literal
+	case p.matching && (p.token.ID == Expr || p.token.ID == Match) && bareSelector.MatchString(p.token.Value):
+		v = p.token.Value
+	default:
+		return p.parseValue(lowestPrecedence)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	return p.parseArithmetic(&Value{Literal: v}, lowestPrecedence)
+}
+
+// parsePresence is responsible for parsing the presence checks i.e. exists(x) and missing(x),
+// which are the equivalent of comparing the selector to null
+func (p *parser) parsePresence() (*Group, error) {
+	e := &Expression{Selector: p.token.Value, Operation: NE, Match: Null{}}
+	if p.token.ID == Missing {
+		e.Operation = EQ
+	}
+	if e.Selector == "" {
+		return nil, newParseError(CodeUnexpectedToken, p.token, []TokenID{Expr},
+			"%s() found at position: %d, expected a selector", p.token.ID, p.token.Start)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	return &Group{Expression: e}, nil
+}
+
+// parseList is responsible for parsing the values of a list i.e. (a, "b", 1)
+func (p *parser) parseList() (*List, error) {
+	opened := p.token
+	if opened.ID != OpenList {
+		return nil, newParseError(CodeUnexpectedToken, p.token, []TokenID{OpenList},
+			"'%s' found at position: %d, expected a list", describe(p.token), p.token.Start)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	list := newList()
+	for p.token.ID != CloseList {
+		switch p.token.ID {
+		case Match, Literal, Boolean, IPAddress, CIDR:
+		default:
+			return nil, newParseError(CodeUnclosedGroup, opened, []TokenID{CloseList},
+				"list opened at position: %d was not closed", opened.Start)
+		}
+		match, err := parseMatch(LogicalEqual, p.token)
+		if err != nil {
+			return nil, err
+		}
+		if _, found := match.(Null); found {
+			return nil, newParseError(CodeInvalidValue, p.token, []TokenID{Match, Literal},
+				"value: null at position: %d cannot be used in a list", p.token.Start)
+		}
+		list.add(match)
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.token.ID == Separator {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// parseMatch is responsible for converting the match token into a value for the operation
+func parseMatch(operation TokenID, i Token) (interface{}, error) {
+	switch i.ID {
+	case Literal:
+		return parseLiteral(operation, i)
+	case Boolean:
+		return i.Value == "true", nil
+	case Duration, Timestamp:
+		// step: the string operations compare against the value as given
+		switch operation {
+		case LogicalEqual, LogicalInvert, LogicalLessThan, LogicalLessThanOrEqual, LogicalGreaterThan, LogicalGreaterThanOrEqual:
+			return parseTime(i)
+		}
+	case SemVer:
+		switch operation {
+		case LogicalEqual, LogicalInvert, LogicalLessThan, LogicalLessThanOrEqual, LogicalGreaterThan, LogicalGreaterThanOrEqual:
+			return parseVersion(i)
+		}
+	case IPAddress, CIDR:
+		switch operation {
+		case LogicalEqual, LogicalInvert, LogicalIn, LogicalNotIn, LogicalLessThan, LogicalLessThanOrEqual, LogicalGreaterThan, LogicalGreaterThanOrEqual:
+			return parseNetwork(i)
+		}
+	}
+	if i.Value == "null" && (operation == LogicalEqual || operation == LogicalInvert) {
+		return Null{}, nil
+	}
+	switch operation {
+	case LogicalLessThan, LogicalLessThanOrEqual, LogicalGreaterThan, LogicalGreaterThanOrEqual:
+		// the match MUST be numeric
+		found, v := parseIfFloat(i.Value)
+		if !found && bareSelector.MatchString(i.Value) {
+			return nil, newParseError(CodeInvalidValue, i, []TokenID{Match},
+				"value: %s at position: %d must be numeric, a duration, a timestamp, a version or a selector named with a $ i.e. $%s when using less or greater than", i.Value, i.Start, i.Value)
+		}
+		if !found {
+			return nil, newParseError(CodeInvalidValue, i, []TokenID{Match},
+				"value: %s at position: %d must be numeric, a duration, a timestamp or a version when using less or greater than", i.Value, i.Start)
+		}
+		return v, nil
+	case LogicalRegex:
+		// the regex MUST be enclosed in forward slashes
+		if len(i.Value) < 2 || !strings.HasPrefix(i.Value, "/") || !strings.HasSuffix(i.Value, "/") {
+			return nil, newParseError(CodeInvalidRegex, i, []TokenID{Match},
+				"regex: '%s' at position: %d must be enclosed in '/'", i.Value, i.Start)
+		}
+		v, err := regexp.Compile(i.Value[1 : len(i.Value)-1])
+		if err != nil {
+			return nil, newParseError(CodeInvalidRegex, i, []TokenID{Match},
+				"regex: '%s' at position: %d is invalid", i.Value, i.Start)
+		}
+		return v, nil
+	case LogicalGlob:
+		return parseGlob(i, i.Value)
+	case LogicalEqual:
+		// step: convert to float if numeric else leave as a string
+		_, v := parseIfFloat(i.Value)
+		return v, nil
+	}
+
+	return i.Value, nil
+}
+
+// parseOperatorMatch is responsible for converting the match token into a value of the type
+// the registered operation takes
+func parseOperatorMatch(op *Operator, i Token) (interface{}, error) {
+	var v interface{}
+	var err error
+	switch i.ID {
+	case Literal:
+		v, err = parseLiteral(LogicalEqual, i)
+	case Boolean:
+		v = i.Value == "true"
+	case Duration, Timestamp:
+		v, err = parseTime(i)
+	case SemVer:
+		v, err = parseVersion(i)
+	case IPAddress, CIDR:
+		v, err = parseNetwork(i)
+	default:
+		_, v = parseIfFloat(i.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := op.Right.convert([]interface{}{v})
+	if len(list) == 0 {
+		return nil, newParseError(CodeInvalidValue, i, []TokenID{Match},
+			"value: %s at position: %d must be a %s when using '%s'", i.Value, i.Start, op.Right.String(), op.Symbol)
+	}
+
+	return list[0], nil
+}
+
+// parseGlob is responsible for compiling the glob pattern into a regex
+func parseGlob(i Token, pattern string) (*regexp.Regexp, error) {
+	expr, err := globToRegex(pattern)
+	if err != nil {
+		return nil, newParseError(CodeInvalidGlob, i, []TokenID{Match, Literal},
+			"glob: '%s' at position: %d is invalid, %s", pattern, i.Start, err)
+	}
+	v, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, newParseError(CodeInvalidGlob, i, []TokenID{Match, Literal},
+			"glob: '%s' at position: %d is invalid", pattern, i.Start)
+	}
+
+	return v, nil
+}
+
+// parseTime is responsible for converting a duration or timestamp token into its value
+func parseTime(i Token) (interface{}, error) {
+	if i.ID == Duration {
+		v, err := time.ParseDuration(i.Value)
+		if err != nil {
+			return nil, newParseError(CodeInvalidValue, i, []TokenID{Duration},
+				"duration: %s at position: %d is invalid", i.Value, i.Start)
+		}
+		return v, nil
+	}
+	v, err := time.Parse(time.RFC3339, i.Value)
+	if err != nil {
+		return nil, newParseError(CodeInvalidValue, i, []TokenID{Timestamp},
+			"timestamp: %s at position: %d is invalid", i.Value, i.Start)
+	}
+
+	return v, nil
+}
+
+// parseVersion is responsible for converting a version token into its value
+func parseVersion(i Token) (interface{}, error) {
+	v, err := ParseVersion(i.Value)
+	if err != nil {
+		return nil, newParseError(CodeInvalidValue, i, []TokenID{SemVer},
+			"version: %s at position: %d is invalid, %s", i.Value, i.Start, err)
+	}
+
+	return v, nil
+}
+
+// parseNetwork is responsible for converting an address or prefix token into its value
+func parseNetwork(i Token) (interface{}, error) {
+	if i.ID == IPAddress {
+		v, err := netip.ParseAddr(i.Value)
+		if err != nil {
+			return nil, newParseError(CodeInvalidValue, i, []TokenID{IPAddress},
+				"address: %s at position: %d is invalid", i.Value, i.Start)
+		}
+		return v.Unmap(), nil
+	}
+	v, err := netip.ParsePrefix(i.Value)
+	if err != nil {
+		return nil, newParseError(CodeInvalidValue, i, []TokenID{CIDR},
+			"prefix: %s at position: %d is invalid", i.Value, i.Start)
+	}
+
+	return v.Masked(), nil
+}
+
+// parseLiteral is responsible for decoding a quoted string, which is always compared as a string
+func parseLiteral(operation TokenID, i Token) (interface{}, error) {
+	v, err := unquote(i.Value)
+	if err != nil {
+		return nil, newParseError(CodeInvalidString, i, []TokenID{Literal},
+			"string: %s at position: %d is invalid, %s", i.Value, i.Start, err)
+	}
+	switch operation {
+	case LogicalLessThan, LogicalLessThanOrEqual, LogicalGreaterThan, LogicalGreaterThanOrEqual:
+		return nil, newParseError(CodeInvalidValue, i, []TokenID{Match},
+			"value: %s at position: %d must be numeric, a duration, a timestamp or a version when using less or greater than", i.Value, i.Start)
+	case LogicalGlob:
+		return parseGlob(i, v)
+	}
+
+	return v, nil
+}
+
+// valueOf is responsible for converting the token into an operand of an arithmetic operation,
+// either a number or a selector, which is negated by a leading minus i.e. -delta. On the right
+// hand side of an operation the selector must be named with a $ i.e. $max_retries - 1
+func (p *parser) valueOf(i Token) (*Value, error) {
+	switch i.ID {
+	case Expr, Match:
+		if found, v := parseIfFloat(i.Value); found {
+			return &Value{Number: v.(float64)}, nil
+		}
+		if name, found := p.selectorOf(i.Value); found {
+			return &Value{Selector: name}, nil
+		}
+		if name, found := p.selectorOf(strings.TrimPrefix(i.Value, "-")); found && strings.HasPrefix(i.Value, "-") {
+			return &Value{Arithmetic: ArithmeticTypeNegate, Left: &Value{Selector: name}}, nil
+		}
+		if p.matching && bareSelector.MatchString(i.Value) {
+			return nil, newParseError(CodeInvalidValue, i, []TokenID{Expr},
+				"value: %s at position: %d must be a number or a selector named with a $ i.e. $%s", i.Value, i.Start, i.Value)
+		}
+	}
+
+	return nil, newParseError(CodeInvalidValue, i, []TokenID{Expr},
+		"value: %s at position: %d must be a number or a selector in an arithmetic expression", describe(i), i.Start)
+}
+
+// selectorOf returns the selector the value names, a bare word only names a selector on the
+// left hand side of an operation
+func (p *parser) selectorOf(value string) (string, bool) {
+	if strings.HasPrefix(value, "$") && bareSelector.MatchString(value[1:]) {
+		return value[1:], true
+	}
+
+	return value, !p.matching && bareSelector.MatchString(value)
+}
+
+// compute is responsible for combining the values with the arithmetic operation, an operation
+// on numbers is folded into a number
+func compute(operation Token, left, right *Value) (*Value, error) {
+	for _, x := range []*Value{left, right} {
+		if t := ValueTypeNumber; x != nil && !t.accepts(x.typeOf()) {
+			given := x.typeOf()
+			return nil, newParseError(CodeInvalidValue, operation, nil,
+				"'%s' found at position: %d cannot be used with a %s value", operation.Value, operation.Start, given.String())
+		}
+	}
+	arithmetic := getArithmetic(operation.ID)
+	if arithmetic == ArithmeticTypeNegate {
+		if left.isConstant() {
+			return &Value{Number: -left.Number}, nil
+		}
+		return &Value{Arithmetic: arithmetic, Left: left}, nil
+	}
+	if !left.isConstant() || !right.isConstant() {
+		return &Value{Arithmetic: arithmetic, Left: left, Right: right}, nil
+	}
+	v, found := arithmetic.apply(left.Number, right.Number)
+	if !found {
+		return nil, newParseError(CodeInvalidValue, operation, nil,
+			"'%s' found at position: %d is a division by zero", operation.Value, operation.Start)
+	}
+
+	return &Value{Number: v}, nil
+}
+
+// checkOrdered is responsible for checking the value can be ordered when used with less or
+// greater than, which a function returning booleans cannot be
+func checkOrdered(operation Token, v *Value) error {
+	switch operation.ID {
+	case LogicalLessThan, LogicalLessThanOrEqual, LogicalGreaterThan, LogicalGreaterThanOrEqual:
+		if v != nil && v.typeOf() == ValueTypeBoolean {
+			return newParseError(CodeInvalidValue, operation, nil,
+				"'%s' found at position: %d cannot be used with a boolean value", operation.Value, operation.Start)
+		}
+	}
+
+	return nil
+}
+
+// isReference checks if the match names a selector, which is a selector prefixed with a dollar
+// used with a comparison, string or registered operation i.e. end_time > $start_time
+func isReference(operation TokenID, i Token) bool {
+	if i.ID != Match || !strings.HasPrefix(i.Value, "$") || !bareSelector.MatchString(i.Value[1:]) {
+		return false
+	}
+	switch operation {
+	case LogicalStartsWith, LogicalEndsWith, LogicalContains, LogicalEqualFold, LogicalCustom:
+		return true
+	}
+
+	return isComparison(operation)
+}
+
+// isNegated checks if the value is a selector with a leading minus i.e. -delta
+func isNegated(value string) bool {
+	return strings.HasPrefix(value, "-") && bareSelector.MatchString(value[1:])
+}
+
+// isComparison checks if the token is an equality or ordering operation
+func isComparison(id TokenID) bool {
+	switch id {
+	case LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual:
+		return true
+	}
+
+	return false
+}
+
+// describe returns the value of the token, or the type when the value is empty
+func describe(token Token) string {
+	if token.Value == "" {
+		return token.ID.String()
+	}
+
+	return token.Value
+}
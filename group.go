@@ -16,49 +16,54 @@ limitations under the License.
 
 package lex
 
-// Add adds an expression to the statement
-func (s *Group) Add() *Expression {
-	if s.Expression == nil {
-		s.Expression = new(Expression)
-		return s.Expression
+// Evaluate is responsible for evaluating the group, the selectors are resolved via the
+// value function. Evaluation is short-circuited, once the outcome of a logical operation
+// is known from the left hand side the right hand side is not resolved.
+//
+// The logic is three valued, a comparison against an absent value is unknown rather than
+// false and the unknown propagates, i.e. the negation of unknown is unknown, false AND
+// unknown is false and true OR unknown is true. The group is only true when the outcome is
+// known to be true, so 'x != 5' and '!(x == 5)' are both false when x is missing
+func (s *Group) Evaluate(fn ValueFn) (bool, error) {
+	t, err := s.evaluate(fn)
+	if err != nil {
+		return false, err
 	}
 
-	expr := s.Last()
-	expr.Next = new(Expression)
-
-	return expr.Next
+	return t == truthTrue, nil
 }
 
-// Current will get the last expression or add one
-func (s *Group) Current() *Expression {
-	e := s.Last()
-	if e == nil {
-		return s.Add()
-	}
+// evaluate is responsible for evaluating the group with three valued logic
+func (s *Group) evaluate(fn ValueFn) (truth, error) {
+	switch s.Logic {
+	case LogicalTypeAnd, LogicalTypeOr:
+		left, err := s.Left.evaluate(fn)
+		if err != nil {
+			return truthFalse, err
+		}
+		if (s.Logic == LogicalTypeAnd && left == truthFalse) || (s.Logic == LogicalTypeOr && left == truthTrue) {
+			return left, nil
+		}
+		right, err := s.Right.evaluate(fn)
+		if err != nil {
+			return truthFalse, err
+		}
+		if left == truthUnknown && right != toTruth(s.Logic == LogicalTypeOr) {
+			return truthUnknown, nil
+		}
 
-	return e
-}
+		return right, nil
+	case LogicalTypeNot:
+		t, err := s.Left.evaluate(fn)
+		if err != nil {
+			return truthFalse, err
+		}
 
-// Last gets the last expression in the group
-func (s *Group) Last() *Expression {
-	if s.Expression == nil {
-		return nil
+		return t.not(), nil
 	}
-	cur := s.Expression
-	for cur.Next != nil {
-		cur = cur.Next
-	}
-
-	return cur
-}
-
-// Size gets the number of expressions in the group
-func (s *Group) Size() int {
-	count := 0
-	for cur := s.Expression; cur != nil; {
-		count++
-		cur = cur.Next
+	if s.Expression == nil {
+		return truthFalse, nil
 	}
 
-	return count
+	return s.Expression.evaluate(fn)
 }
@@ -17,65 +17,178 @@ limitations under the License.
 package lex
 
 import (
-	"fmt"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestGroupAddWhenEmpty(t *testing.T) {
-	st := new(Group)
-	assert.Nil(t, st.Expression)
-	st.Add()
-	assert.NotNil(t, st.Expression)
-	assert.Nil(t, st.Expression.Next)
-}
-
-func TestGetCurrentExpression(t *testing.T) {
-	st := new(Group)
-	assert.NotNil(t, st)
-	assert.Nil(t, st.Last())
-	assert.NotNil(t, st.Current())
-}
-
-func TestGroupAdd(t *testing.T) {
-	st := new(Group)
-	st.Add()
-	assert.NotNil(t, st.Expression)
-	st.Add()
-	assert.NotNil(t, st.Expression.Next)
-}
-
-func TestGroupLastWhenEmpty(t *testing.T) {
-	st := new(Group)
-	assert.Nil(t, st.Last())
+func TestGroupEvaluate(t *testing.T) {
+	values := map[string][]interface{}{
+		"a": {1},
+		"b": {2},
+		"c": {"test"},
+	}
+	cs := []struct {
+		Group    *Group
+		Expected bool
+	}{
+		{Group: new(Group)},
+		{
+			Group:    &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}},
+			Expected: true,
+		},
+		{
+			Group: &Group{
+				Logic: LogicalTypeAnd,
+				Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}},
+				Right: &Group{Expression: &Expression{Selector: "b", Operation: EQ, Match: 1.0}},
+			},
+		},
+		{
+			Group: &Group{
+				Logic: LogicalTypeOr,
+				Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 2.0}},
+				Right: &Group{Expression: &Expression{Selector: "c", Operation: EQ, Match: "test"}},
+			},
+			Expected: true,
+		},
+		{
+			Group: &Group{
+				Logic: LogicalTypeOr,
+				Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 2.0}},
+				Right: &Group{
+					Logic: LogicalTypeAnd,
+					Left:  &Group{Expression: &Expression{Selector: "b", Operation: LT, Match: 5.0}},
+					Right: &Group{Expression: &Expression{Selector: "c", Operation: NE, Match: "test"}},
+				},
+			},
+		},
+		{
+			Group: &Group{
+				Logic: LogicalTypeAnd,
+				Left: &Group{
+					Logic: LogicalTypeOr,
+					Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 2.0}},
+					Right: &Group{Expression: &Expression{Selector: "b", Operation: LT, Match: 5.0}},
+				},
+				Right: &Group{Expression: &Expression{Selector: "c", Operation: EQ, Match: "test"}},
+			},
+			Expected: true,
+		},
+		{
+			Group: &Group{
+				Logic: LogicalTypeNot,
+				Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 2.0}},
+			},
+			Expected: true,
+		},
+	}
+	fn := func(selector string) ([]interface{}, error) {
+		return values[selector], nil
+	}
+	for i, c := range cs {
+		matched, err := c.Group.Evaluate(fn)
+		assert.NoError(t, err, "case %d, should not have returned an error", i)
+		assert.Equal(t, c.Expected, matched, "case %d, expected: %t, got: %t", i, c.Expected, matched)
+	}
 }
 
-func TestGroupLast(t *testing.T) {
-	st := new(Group)
-	assert.Nil(t, st.Last())
-	e := st.Add()
-	e.Match = "test"
-	assert.NotNil(t, st.Last())
-	assert.Equal(t, "test", st.Last().Match)
+func TestGroupEvaluateShortCircuit(t *testing.T) {
+	cs := []struct {
+		Group    *Group
+		Resolved []string
+	}{
+		{
+			Group: &Group{
+				Logic: LogicalTypeAnd,
+				Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 2.0}},
+				Right: &Group{
+					Logic: LogicalTypeOr,
+					Left:  &Group{Expression: &Expression{Selector: "b", Operation: EQ, Match: 1.0}},
+					Right: &Group{Expression: &Expression{Selector: "c", Operation: EQ, Match: 1.0}},
+				},
+			},
+			Resolved: []string{"a"},
+		},
+		{
+			Group: &Group{
+				Logic: LogicalTypeOr,
+				Left: &Group{
+					Logic: LogicalTypeAnd,
+					Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}},
+					Right: &Group{Expression: &Expression{Selector: "b", Operation: EQ, Match: 1.0}},
+				},
+				Right: &Group{Expression: &Expression{Selector: "c", Operation: EQ, Match: 1.0}},
+			},
+			Resolved: []string{"a", "b"},
+		},
+	}
+	for i, c := range cs {
+		var resolved []string
+		_, err := c.Group.Evaluate(func(selector string) ([]interface{}, error) {
+			resolved = append(resolved, selector)
+			return []interface{}{1}, nil
+		})
+		assert.NoError(t, err, "case %d, should not have returned an error", i)
+		assert.Equal(t, c.Resolved, resolved, "case %d, resolved the wrong selectors", i)
+	}
 }
 
-func TestGroupLastWithMany(t *testing.T) {
-	st := new(Group)
-	for i := 1; i <= 5; i++ {
-		st.Add().Match = fmt.Sprintf("test%d", i)
+func TestGroupEvaluateUnknown(t *testing.T) {
+	// t is true, f is false and u is unknown as the selector is missing
+	t1 := &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}}
+	f1 := &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 2.0}}
+	u1 := &Group{Expression: &Expression{Selector: "missing", Operation: EQ, Match: 1.0}}
+	cs := []struct {
+		Group    *Group
+		Expected truth
+	}{
+		{Group: u1, Expected: truthUnknown},
+		{Group: &Group{Logic: LogicalTypeNot, Left: u1}, Expected: truthUnknown},
+		{Group: &Group{Logic: LogicalTypeAnd, Left: u1, Right: t1}, Expected: truthUnknown},
+		{Group: &Group{Logic: LogicalTypeAnd, Left: t1, Right: u1}, Expected: truthUnknown},
+		{Group: &Group{Logic: LogicalTypeAnd, Left: u1, Right: f1}, Expected: truthFalse},
+		{Group: &Group{Logic: LogicalTypeAnd, Left: f1, Right: u1}, Expected: truthFalse},
+		{Group: &Group{Logic: LogicalTypeAnd, Left: u1, Right: u1}, Expected: truthUnknown},
+		{Group: &Group{Logic: LogicalTypeOr, Left: u1, Right: t1}, Expected: truthTrue},
+		{Group: &Group{Logic: LogicalTypeOr, Left: t1, Right: u1}, Expected: truthTrue},
+		{Group: &Group{Logic: LogicalTypeOr, Left: u1, Right: f1}, Expected: truthUnknown},
+		{Group: &Group{Logic: LogicalTypeOr, Left: f1, Right: u1}, Expected: truthUnknown},
+		{Group: &Group{Logic: LogicalTypeNot, Left: &Group{Logic: LogicalTypeOr, Left: u1, Right: t1}}, Expected: truthFalse},
+	}
+	fn := func(selector string) ([]interface{}, error) {
+		if selector == "a" {
+			return []interface{}{1}, nil
+		}
+		return nil, nil
+	}
+	for i, c := range cs {
+		result, err := c.Group.evaluate(fn)
+		assert.NoError(t, err, "case %d, should not have returned an error", i)
+		assert.Equal(t, c.Expected, result, "case %d, expected: %d, got: %d", i, c.Expected, result)
+		matched, _ := c.Group.Evaluate(fn)
+		assert.Equal(t, c.Expected == truthTrue, matched, "case %d", i)
 	}
-	assert.Equal(t, "test5", st.Last().Match)
 }
 
-func TestGroupSizeWhenEmpty(t *testing.T) {
-	assert.Equal(t, 0, new(Group).Size())
+func TestGroupEvaluateValueError(t *testing.T) {
+	g := &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}}
+	matched, err := g.Evaluate(func(string) ([]interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	assert.Error(t, err)
+	assert.False(t, matched)
 }
 
-func TestGroupSize(t *testing.T) {
-	st := new(Group)
-	for i := 0; i < 5; i++ {
-		st.Add().Match = fmt.Sprintf("test%d", i)
-	}
-	assert.Equal(t, 5, st.Size())
+func TestGroupEvaluateReferenceError(t *testing.T) {
+	g := &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: Reference("b")}}
+	matched, err := g.Evaluate(func(name string) ([]interface{}, error) {
+		if name == "b" {
+			return nil, errors.New("failed")
+		}
+		return []interface{}{1}, nil
+	})
+	assert.Error(t, err)
+	assert.False(t, matched)
 }
@@ -18,154 +18,141 @@ package lex
 
 import (
 	"errors"
-	"fmt"
 	"regexp"
 )
 
 var (
 	defaultExpr = regexp.MustCompile("")
+	// bareSelector is the form a selector without an operation must take
+	bareSelector = regexp.MustCompile(`^[a-zA-Z_][\w.\-\[\]]*$`)
+	// functionName is the form the name of a function must take
+	functionName = regexp.MustCompile(`^[a-zA-Z_]\w*$`)
 
 	// parsingRules is the ruleset defined the ordering of tokens, i.e. what can the token follow
 	parsingRules = map[TokenID][]TokenID{
-		CloseGroup:                {CloseGroup, Match},
+		ArithmeticAdd:             {Expr, Match, CloseGroup},
+		ArithmeticDivide:          {Expr, Match, CloseGroup},
+		ArithmeticModulo:          {Expr, Match, CloseGroup},
+		ArithmeticMultiply:        {Expr, Match, CloseGroup},
+		ArithmeticNegate:          {OpenGroup, Entry, LogicalAnd, LogicalOr, LogicalNot, LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual, ArithmeticAdd, ArithmeticSubtract, ArithmeticMultiply, ArithmeticDivide, ArithmeticModulo, FunctionCall, Separator, LogicalCustom},
+		ArithmeticSubtract:        {Expr, Match, CloseGroup},
+		Boolean:                   {LogicalEqual, LogicalInvert, OpenList, Separator, LogicalCustom},
+		CIDR:                      {LogicalEqual, LogicalInvert, LogicalIn, LogicalNotIn, OpenList, Separator, LogicalStartsWith, LogicalEndsWith, LogicalContains, LogicalEqualFold, LogicalGlob, LogicalCustom},
+		CloseGroup:                {CloseGroup, CloseList, Match, Literal, Exists, Missing, Boolean, Duration, Timestamp, SemVer, IPAddress, CIDR, Expr, FunctionCall},
+		CloseList:                 {OpenList, Match, Literal, Boolean, IPAddress, CIDR},
+		Duration:                  {LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual, LogicalStartsWith, LogicalEndsWith, LogicalContains, LogicalEqualFold, LogicalGlob, LogicalCustom},
 		Entry:                     {},
-		EOF:                       {Match, CloseGroup},
-		Expr:                      {OpenGroup, Entry, LogicalAnd, LogicalOr},
-		LogicalAnd:                {CloseGroup, Match},
-		LogicalEqual:              {Expr},
-		LogicalGreaterThan:        {Expr},
-		LogicalGreaterThanOrEqual: {Expr},
-		LogicalInvert:             {Expr},
-		LogicalLessThan:           {Expr},
-		LogicalLessThanOrEqual:    {Expr},
-		LogicalOr:                 {CloseGroup, Match},
-		LogicalRegex:              {Expr},
-		Match:                     {LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual},
-		OpenGroup:                 {OpenGroup, LogicalAnd, LogicalOr, Entry},
+		EOF:                       {Match, CloseGroup, CloseList, Literal, Exists, Missing, Boolean, Duration, Timestamp, SemVer, IPAddress, CIDR, Expr},
+		Exists:                    {OpenGroup, Entry, LogicalAnd, LogicalOr, LogicalNot},
+		Expr:                      {OpenGroup, Entry, LogicalAnd, LogicalOr, LogicalNot, Quantifier, ArithmeticAdd, ArithmeticSubtract, ArithmeticMultiply, ArithmeticDivide, ArithmeticModulo, FunctionCall, Separator},
+		FunctionCall:              {OpenGroup, Entry, LogicalAnd, LogicalOr, LogicalNot, FunctionCall, Separator, ArithmeticAdd, ArithmeticSubtract, ArithmeticMultiply, ArithmeticDivide, ArithmeticModulo, ArithmeticNegate, LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual, LogicalStartsWith, LogicalEndsWith, LogicalContains, LogicalEqualFold, LogicalCustom},
+		IPAddress:                 {LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual, OpenList, Separator, LogicalStartsWith, LogicalEndsWith, LogicalContains, LogicalEqualFold, LogicalGlob, LogicalCustom},
+		Literal:                   {LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual, OpenList, Separator, LogicalStartsWith, LogicalEndsWith, LogicalContains, LogicalEqualFold, LogicalGlob, FunctionCall, LogicalCustom},
+		LogicalAnd:                {CloseGroup, CloseList, Match, Literal, Exists, Missing, Boolean, Duration, Timestamp, SemVer, IPAddress, CIDR, Expr},
+		LogicalContains:           {Expr, CloseGroup},
+		LogicalCustom:             {Expr, CloseGroup},
+		LogicalEndsWith:           {Expr, CloseGroup},
+		LogicalEqual:              {Expr, CloseGroup},
+		LogicalEqualFold:          {Expr, CloseGroup},
+		LogicalGlob:               {Expr, CloseGroup},
+		LogicalGreaterThan:        {Expr, CloseGroup},
+		LogicalGreaterThanOrEqual: {Expr, CloseGroup},
+		LogicalIn:                 {Expr, CloseGroup},
+		LogicalInvert:             {Expr, CloseGroup},
+		LogicalLessThan:           {Expr, CloseGroup},
+		LogicalLessThanOrEqual:    {Expr, CloseGroup},
+		LogicalNot:                {OpenGroup, Entry, LogicalAnd, LogicalOr, LogicalNot},
+		LogicalNotIn:              {Expr, CloseGroup},
+		LogicalOr:                 {CloseGroup, CloseList, Match, Literal, Exists, Missing, Boolean, Duration, Timestamp, SemVer, IPAddress, CIDR, Expr},
+		LogicalRegex:              {Expr, CloseGroup},
+		LogicalStartsWith:         {Expr, CloseGroup},
+		Match:                     {LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual, LogicalRegex, OpenList, Separator, LogicalStartsWith, LogicalEndsWith, LogicalContains, LogicalEqualFold, LogicalGlob, LogicalCustom},
+		Missing:                   {OpenGroup, Entry, LogicalAnd, LogicalOr, LogicalNot},
+		OpenGroup:                 {OpenGroup, LogicalAnd, LogicalOr, Entry, LogicalNot, LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual, ArithmeticAdd, ArithmeticSubtract, ArithmeticMultiply, ArithmeticDivide, ArithmeticModulo, ArithmeticNegate, FunctionCall, Separator, LogicalCustom},
+		OpenList:                  {LogicalIn, LogicalNotIn},
+		Quantifier:                {OpenGroup, LogicalAnd, LogicalOr, Entry, LogicalNot},
+		SemVer:                    {LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual, LogicalStartsWith, LogicalEndsWith, LogicalContains, LogicalEqualFold, LogicalGlob, LogicalCustom},
+		Separator:                 {Match, Literal, Boolean, IPAddress, CIDR, Expr, CloseGroup},
+		Timestamp:                 {LogicalEqual, LogicalInvert, LogicalGreaterThan, LogicalGreaterThanOrEqual, LogicalLessThan, LogicalLessThanOrEqual, LogicalStartsWith, LogicalEndsWith, LogicalContains, LogicalEqualFold, LogicalGlob, LogicalCustom},
 	}
 )
 
-// New is responsible for creating a new lexer
-func New(input string) *Lexer {
-	return &Lexer{
+// New is responsible for creating a new lexer, the options configure the language i.e. the
+// functions which can be called
+func New(input string, options ...Option) *Lexer {
+	l := &Lexer{
 		input:    input,
 		validFn:  validateExpression,
 		listener: make([]TokenChannel, 0),
 	}
+	for _, fn := range options {
+		fn(l)
+	}
+
+	return l
 }
 
-// Parse is responsible for parsing the input stream
+// Parse is responsible for parsing the input stream into a tree of groups, the logical
+// AND binds tighter than the logical OR and both associate to the left. Parsing stops at
+// the first error, which is returned as a *ParseError
 func (l *Lexer) Parse() (*Group, error) {
-	var lastToken Token // the previous token we got
-	var p, c *Group     // a reference to the current Group
-
-	root := new(Group)
-	for i := range newTokenizer(l.input) {
-		// emit the token to any listeners
-		l.emitTokenListener(i)
-		// if we have a previous token check against the ruleset
-		if lastToken.ID != Unknown {
-			if !validateTokenRules(lastToken.ID, parsingRules[i.ID]) {
-				return nil, fmt.Errorf("'%s' found at position: %d cannot follow '%s'", i.Value, i.Start, lastToken.Value)
-			}
-		}
-		// parse the token
-		switch i.ID {
-		case Entry:
-			c = root
-		case EOF:
-		case OpenGroup:
-			p = c
-			ng := new(Group)
-			switch c.Next {
-			case nil:
-				c.Next = ng
-			default:
-				c.Next.Next = ng
-			}
-			c = ng
-		case CloseGroup:
-			if p == nil {
-				return nil, fmt.Errorf("')' closed as position: %d was not opened", i.Start)
-			}
-			c = p
-			p = nil
-		case LogicalAnd:
-			switch lastToken.ID {
-			case CloseGroup:
-				c.Logic = LogicalTypeAnd
-			case Match:
-				c.Last().Logic = LogicalTypeAnd
-			}
-		case LogicalOr:
-		case Expr:
-			if c.Current().Selector != "" {
-				c.Add()
-			}
-			c.Current().Selector = i.Value
-		case Match:
-			switch lastToken.ID {
-			case LogicalLessThan:
-				fallthrough
-			case LogicalLessThanOrEqual:
-				fallthrough
-			case LogicalGreaterThan:
-				fallthrough
-			case LogicalGreaterThanOrEqual:
-				// the match MUST be numeric
-				found, v := parseIfFloat(i.Value)
-				if !found {
-					return nil, fmt.Errorf("value: %s at position: %d must be numeric when using less or greater than", i.Value, i.Start)
-				}
-				c.Last().Match = v
-			case LogicalRegex:
-				v, err := regexp.Compile(i.Value)
-				if err != nil {
-					return nil, fmt.Errorf("regex: '%s' at position: %d is invalid", i.Value, i.Start)
-				}
-				c.Last().Match = v
-			case LogicalEqual:
-				// step: convert to float if numeric else leave as a string
-				_, v := parseIfFloat(i.Value)
-				c.Last().Match = v
-			default:
-				c.Last().Match = i.Value
-			}
-		case LogicalRegex:
-			fallthrough
-		case LogicalEqual, LogicalInvert:
-			fallthrough
-		case LogicalGreaterThan, LogicalGreaterThanOrEqual:
-			fallthrough
-		case LogicalLessThan, LogicalLessThanOrEqual:
-			c.Last().Operation = getOperation(i.ID)
-		default:
-			panic("invalid token recieved")
+	root, err := newParser(l, false).parse()
+	if err != nil {
+		if e, ok := err.(*ParseError); ok {
+			e.locate(l.input)
 		}
-		lastToken = i // update the p token
+		return nil, err
 	}
 
 	return root, nil
 }
 
-// Evaluate is responsible for evaluating the expression
-func (l *Lexer) Evaluate() error {
+// ParseAll is responsible for parsing the input stream, recovering from errors at the
+// next '&&', '||' or ')' so every problem in the input is found. The errors are returned
+// as ParseErrors along with the partial tree, the parts which failed are empty groups
+func (l *Lexer) ParseAll() (*Group, error) {
+	p := newParser(l, true)
+	root, err := p.parse()
+	if err != nil {
+		p.fail(err)
+	}
+	if len(p.errors) == 0 {
+		return root, nil
+	}
+	for _, e := range p.errors {
+		e.locate(l.input)
+	}
 
-	return nil
+	return root, p.errors
 }
 
-// AddListener adds a listener to the streams of token produced by the parser
+// Evaluate is responsible for parsing and evaluating the expression, using the value
+// function to resolve the selectors
+func (l *Lexer) Evaluate(fn ValueFn) (bool, error) {
+	root, err := l.Parse()
+	if err != nil {
+		return false, err
+	}
+
+	return root.Evaluate(fn)
+}
+
+// AddListener adds a listener to the streams of token produced by the parser. The tokens
+// are sent as they are parsed without waiting on the listener, a token is dropped when the
+// channel is not ready to receive it, so the channel should be buffered to hold the tokens
 func (l *Lexer) AddListener(ch TokenChannel) *Lexer {
 	l.listener = append(l.listener, ch)
 	return l
 }
 
-// emitTokenListener is responsible for forwarding the tokens to the listeners
+// emitTokenListener is responsible for forwarding the token to the listeners which are ready
+// to receive it, the parser never blocks on a listener
 func (l *Lexer) emitTokenListener(token Token) {
 	for _, ch := range l.listener {
-		go func(c TokenChannel) {
-			c <- token
-		}(ch)
+		select {
+		case ch <- token:
+		default:
+		}
 	}
 }
 
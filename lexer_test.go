@@ -17,7 +17,13 @@ limitations under the License.
 package lex
 
 import (
+	"errors"
+	"math"
+	"net"
+	"net/netip"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/stretchr/testify/assert"
@@ -35,11 +41,35 @@ func TestAddTokenListener(t *testing.T) {
 	assert.Equal(t, 1, len(l.listener))
 }
 
+func TestTokenListener(t *testing.T) {
+	undrained, full := make(TokenChannel), make(TokenChannel, 2)
+	buffered := make(TokenChannel, 10)
+	l := New("test == 1 && a > 2").AddListener(undrained).AddListener(full).AddListener(buffered)
+
+	done := make(chan error)
+	go func() {
+		_, err := l.Parse()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("parse blocked on the listeners")
+	}
+	assert.Len(t, full, 2)
+	close(buffered)
+	var values []string
+	for x := range buffered {
+		values = append(values, x.Value)
+	}
+	assert.Equal(t, []string{"", "test", "==", "1", "&&", "a", ">", "2", ""}, values)
+}
+
 func TestParseRulesBad(t *testing.T) {
 	cs := []struct {
 		Input string // is the input expression
 	}{
-		{Input: "test"},
 		{Input: "test == )"},
 		{Input: "test &&"},
 		{Input: "test & tes"},
@@ -49,6 +79,9 @@ func TestParseRulesBad(t *testing.T) {
 		{Input: "test != ()"},
 		{Input: "(test=1)(test=1)"},
 		{Input: "(test||1)"},
+		{Input: "((test == 1)"},
+		{Input: "((test == 1) && (test == 2)"},
+		{Input: "(test == 1))"},
 	}
 	for _, c := range cs {
 		st, err := New(c.Input).Parse()
@@ -61,7 +94,6 @@ func TestParseBad(t *testing.T) {
 	cs := []struct {
 		Input string // is the input for the expression
 	}{
-		{Input: "test"},
 		{Input: "test =~ /test"},
 		{Input: "test =~ test/"},
 		{Input: "test =~ /dsd$"},
@@ -69,6 +101,8 @@ func TestParseBad(t *testing.T) {
 		{Input: "test >= djshdj"},
 		{Input: "test <= 3232ldd"},
 		{Input: "test < dsdsd"},
+		{Input: ")"},
+		{Input: "a == 1 && )"},
 	}
 	for _, c := range cs {
 		st, err := New(c.Input).Parse()
@@ -77,138 +111,235 @@ func TestParseBad(t *testing.T) {
 	}
 }
 
+func TestParseAll(t *testing.T) {
+	cs := []struct {
+		Input   string
+		Codes   []ErrorCode
+		Offsets []int
+	}{
+		{
+			Input:   "a > x && b => 1 || (c == 3 && d =~ /[/)",
+			Codes:   []ErrorCode{CodeInvalidValue, CodeInvalidValue, CodeInvalidRegex},
+			Offsets: []int{4, 11, 35},
+		},
+		{
+			Input:   "(a == 1 && (b == 2",
+			Codes:   []ErrorCode{CodeUnclosedGroup, CodeUnclosedGroup},
+			Offsets: []int{11, 0},
+		},
+		{
+			Input:   "a == 1 & b == 2 || c === 3",
+			Codes:   []ErrorCode{CodeUnexpectedToken, CodeInvalidValue},
+			Offsets: []int{7, 21},
+		},
+		{
+			Input:   "a == 1) || b == 2",
+			Codes:   []ErrorCode{CodeUnopenedGroup},
+			Offsets: []int{6},
+		},
+		{
+			Input:   "a + \"b\" > 1 || c > 1 / 0 || (d == 1) * 2 > 1",
+			Codes:   []ErrorCode{CodeInvalidValue, CodeInvalidValue, CodeUnexpectedToken},
+			Offsets: []int{4, 21, 37},
+		},
+		{
+			Input:   "(a == 1 && b == ) || c == 3",
+			Codes:   []ErrorCode{CodeInvalidValue},
+			Offsets: []int{16},
+		},
+		{
+			Input:   "a === 1 && b => 2 || (c == && d == 4)",
+			Codes:   []ErrorCode{CodeInvalidValue, CodeInvalidValue, CodeInvalidValue},
+			Offsets: []int{2, 13, 27},
+		},
+	}
+	for i, c := range cs {
+		root, err := New(c.Input).ParseAll()
+		assert.NotNil(t, root, "case %d, expected a partial tree", i)
+		var list ParseErrors
+		if !assert.True(t, errors.As(err, &list), "case %d, expected parse errors", i) {
+			continue
+		}
+		if !assert.Equal(t, len(c.Codes), len(list), "case %d, errors: %s", i, err) {
+			continue
+		}
+		for j, x := range list {
+			assert.Equal(t, c.Codes[j], x.Code, "case %d, error %d", i, j)
+			assert.Equal(t, c.Offsets[j], x.Offset, "case %d, error %d", i, j)
+		}
+		assert.True(t, errors.Is(err, ErrInvalidExpression), "case %d", i)
+	}
+}
+
+func TestParseAllPartial(t *testing.T) {
+	root, err := New("a == 1 && (b == 2").ParseAll()
+	assert.Error(t, err)
+	expected := &Group{
+		Logic: LogicalTypeAnd,
+		Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: float64(1)}},
+		Right: &Group{Expression: &Expression{Selector: "b", Operation: EQ, Match: float64(2)}},
+	}
+	assert.Equal(t, expected, root)
+
+	root, err = New("a == 1 || b => 2").ParseAll()
+	assert.Error(t, err)
+	expected = &Group{
+		Logic: LogicalTypeOr,
+		Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: float64(1)}},
+		Right: &Group{},
+	}
+	assert.Equal(t, expected, root)
+}
+
+func TestParseAllOk(t *testing.T) {
+	root, err := New("a == 1 && b == 2").ParseAll()
+	assert.NoError(t, err)
+	expected, _ := New("a == 1 && b == 2").Parse()
+	assert.Equal(t, expected, root)
+}
+
+func TestParseErrorsRender(t *testing.T) {
+	_, err := New("a => 1 || b == 2)").ParseAll()
+	list, ok := err.(ParseErrors)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, "2 errors found: '=' is missing a value at position: 2; "+
+		"')' closed as position: 16 was not opened", list.Error())
+	assert.Equal(t, "line 1, column 3: '=' is missing a value at position: 2\n"+
+		"a => 1 || b == 2)\n"+
+		"  ^~\n"+
+		"did you mean '>='?\n\n"+
+		"line 1, column 17: ')' closed as position: 16 was not opened\n"+
+		"a => 1 || b == 2)\n"+
+		"                ^", list.Render())
+}
+
 func TestParseOk(t *testing.T) {
 	cs := []struct {
 		Input  string
 		Output *Group
 	}{
 		{
-			Input: "(test == 1)",
+			Input:  "(test == 1)",
+			Output: &Group{Expression: &Expression{Selector: "test", Operation: EQ, Match: 1.0}},
+		},
+		{
+			Input:  "test == 1",
+			Output: &Group{Expression: &Expression{Selector: "test", Operation: EQ, Match: 1.0}},
+		},
+		{
+			Input: "test == 1 && test > 5",
+			Output: &Group{
+				Logic: LogicalTypeAnd,
+				Left:  &Group{Expression: &Expression{Selector: "test", Operation: EQ, Match: 1.0}},
+				Right: &Group{Expression: &Expression{Selector: "test", Operation: GT, Match: 5.0}},
+			},
+		},
+		{
+			Input: "test == 1 && test > 5 || test > 19",
 			Output: &Group{
-				Next: &Group{
-					Expression: &Expression{Selector: "test", Operation: EQ, Match: 1.0},
+				Logic: LogicalTypeOr,
+				Left: &Group{
+					Logic: LogicalTypeAnd,
+					Left:  &Group{Expression: &Expression{Selector: "test", Operation: EQ, Match: 1.0}},
+					Right: &Group{Expression: &Expression{Selector: "test", Operation: GT, Match: 5.0}},
 				},
+				Right: &Group{Expression: &Expression{Selector: "test", Operation: GT, Match: 19.0}},
 			},
 		},
 		{
-			Input: "test == 1",
+			Input: "test == 1 || test > 5 && test > 19",
 			Output: &Group{
-				Expression: &Expression{Selector: "test", Operation: EQ, Match: 1.0},
+				Logic: LogicalTypeOr,
+				Left:  &Group{Expression: &Expression{Selector: "test", Operation: EQ, Match: 1.0}},
+				Right: &Group{
+					Logic: LogicalTypeAnd,
+					Left:  &Group{Expression: &Expression{Selector: "test", Operation: GT, Match: 5.0}},
+					Right: &Group{Expression: &Expression{Selector: "test", Operation: GT, Match: 19.0}},
+				},
 			},
 		},
 		{
-			Input: "test == 1 && test > 5",
+			Input: "a == 1 && b == 2 && c == 3",
 			Output: &Group{
-				Expression: &Expression{
-					Selector:  "test",
-					Operation: EQ,
-					Match:     1.0,
-					Logic:     LogicalTypeAnd,
-					Next: &Expression{
-						Selector:  "test",
-						Operation: GT,
-						Match:     5.0,
-					},
+				Logic: LogicalTypeAnd,
+				Left: &Group{
+					Logic: LogicalTypeAnd,
+					Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}},
+					Right: &Group{Expression: &Expression{Selector: "b", Operation: EQ, Match: 2.0}},
 				},
+				Right: &Group{Expression: &Expression{Selector: "c", Operation: EQ, Match: 3.0}},
 			},
 		},
 		{
-			Input: "test == 1 && test > 5 || test > 19",
+			Input: "a == 1 || b == 2 && c == 3 || d == 4",
 			Output: &Group{
 				Logic: LogicalTypeOr,
-				Expression: &Expression{
-					Selector:  "test",
-					Operation: EQ,
-					Match:     1.0,
-					Logic:     LogicalTypeAnd,
-					Next: &Expression{
-						Selector:  "test",
-						Operation: GT,
-						Match:     5.0,
-						Logic:     LogicalTypeOr,
-						Next: &Expression{
-							Selector:  "test",
-							Operation: GT,
-							Match:     19.0,
-						},
+				Left: &Group{
+					Logic: LogicalTypeOr,
+					Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}},
+					Right: &Group{
+						Logic: LogicalTypeAnd,
+						Left:  &Group{Expression: &Expression{Selector: "b", Operation: EQ, Match: 2.0}},
+						Right: &Group{Expression: &Expression{Selector: "c", Operation: EQ, Match: 3.0}},
 					},
 				},
+				Right: &Group{Expression: &Expression{Selector: "d", Operation: EQ, Match: 4.0}},
 			},
 		},
 		{
 			Input: "(test == 1 || test > 5) && test >= 19",
 			Output: &Group{
-				Expression: &Expression{
-					Selector:  "test",
-					Operation: GTE,
-					Match:     19.0,
-				},
 				Logic: LogicalTypeAnd,
-				Next: &Group{
-					Expression: &Expression{
-						Selector:  "test",
-						Operation: EQ,
-						Match:     1.0,
-						Logic:     LogicalTypeOr,
-						Next: &Expression{
-							Selector:  "test",
-							Operation: GT,
-							Match:     5.0,
-						},
-					},
+				Left: &Group{
+					Logic: LogicalTypeOr,
+					Left:  &Group{Expression: &Expression{Selector: "test", Operation: EQ, Match: 1.0}},
+					Right: &Group{Expression: &Expression{Selector: "test", Operation: GT, Match: 5.0}},
 				},
+				Right: &Group{Expression: &Expression{Selector: "test", Operation: GTE, Match: 19.0}},
 			},
 		},
 		{
 			Input: "test==2||test>0",
 			Output: &Group{
-				Expression: &Expression{
-					Selector:  "test",
-					Operation: EQ,
-					Match:     2.0,
-					Logic:     LogicalTypeOr,
-					Next: &Expression{
-						Selector:  "test",
-						Operation: GT,
-						Match:     0.0,
-					},
-				},
+				Logic: LogicalTypeOr,
+				Left:  &Group{Expression: &Expression{Selector: "test", Operation: EQ, Match: 2.0}},
+				Right: &Group{Expression: &Expression{Selector: "test", Operation: GT, Match: 0.0}},
 			},
 		},
 		{
-			Input: "(test==2)||test>0",
+			Input: "(test==2)&&(test>0)",
+			Output: &Group{
+				Logic: LogicalTypeAnd,
+				Left:  &Group{Expression: &Expression{Selector: "test", Operation: EQ, Match: 2.0}},
+				Right: &Group{Expression: &Expression{Selector: "test", Operation: GT, Match: 0.0}},
+			},
+		},
+		{
+			Input: "((a == 1) && (b == 2)) || c == 3",
 			Output: &Group{
-				Expression: &Expression{
-					Selector:  "test",
-					Operation: GT,
-					Match:     0.0,
-				},
 				Logic: LogicalTypeOr,
-				Next: &Group{
-					Expression: &Expression{
-						Selector:  "test",
-						Operation: EQ,
-						Match:     2.0,
-					},
+				Left: &Group{
+					Logic: LogicalTypeAnd,
+					Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}},
+					Right: &Group{Expression: &Expression{Selector: "b", Operation: EQ, Match: 2.0}},
 				},
+				Right: &Group{Expression: &Expression{Selector: "c", Operation: EQ, Match: 3.0}},
 			},
 		},
 		{
-			Input: "(test==2)&&(test>0)",
+			Input: "a == 1 && (b == 2 || (c == 3 && (d == 4)))",
 			Output: &Group{
 				Logic: LogicalTypeAnd,
-				Next: &Group{
-					Expression: &Expression{
-						Selector:  "test",
-						Operation: EQ,
-						Match:     2.0,
-					},
-					Next: &Group{
-						Expression: &Expression{
-							Selector:  "test",
-							Operation: GT,
-							Match:     0.0,
-						},
+				Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}},
+				Right: &Group{
+					Logic: LogicalTypeOr,
+					Left:  &Group{Expression: &Expression{Selector: "b", Operation: EQ, Match: 2.0}},
+					Right: &Group{
+						Logic: LogicalTypeAnd,
+						Left:  &Group{Expression: &Expression{Selector: "c", Operation: EQ, Match: 3.0}},
+						Right: &Group{Expression: &Expression{Selector: "d", Operation: EQ, Match: 4.0}},
 					},
 				},
 			},
@@ -230,12 +361,1054 @@ func TestParseWithRegex(t *testing.T) {
 				Expression: &Expression{Selector: "test", Operation: EQ, Match: 1.0},
 			},
 		},
+		{
+			Input: "test =~ /^te.*$/",
+			Output: &Group{
+				Expression: &Expression{Selector: "test", Operation: LIKE, Match: regexp.MustCompile("^te.*$")},
+			},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseQuantifier(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input: "all(ports) < 1024",
+			Output: &Group{
+				Expression: &Expression{Selector: "ports", Quantifier: QuantifierAll, Operation: LT, Match: 1024.0},
+			},
+		},
+		{
+			Input: "name == test || any(tags) == prod",
+			Output: &Group{
+				Logic: LogicalTypeOr,
+				Left:  &Group{Expression: &Expression{Selector: "name", Operation: EQ, Match: "test"}},
+				Right: &Group{Expression: &Expression{Selector: "tags", Quantifier: QuantifierAny, Operation: EQ, Match: "prod"}},
+			},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseNot(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input: "!(a == 1 || b == 2)",
+			Output: &Group{
+				Logic: LogicalTypeNot,
+				Left: &Group{
+					Logic: LogicalTypeOr,
+					Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}},
+					Right: &Group{Expression: &Expression{Selector: "b", Operation: EQ, Match: 2.0}},
+				},
+			},
+		},
+		{
+			Input: "!a == 1 && b == 2",
+			Output: &Group{
+				Logic: LogicalTypeAnd,
+				Left: &Group{
+					Logic: LogicalTypeNot,
+					Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}},
+				},
+				Right: &Group{Expression: &Expression{Selector: "b", Operation: EQ, Match: 2.0}},
+			},
+		},
+		{
+			Input: "!!a == 1",
+			Output: &Group{
+				Logic: LogicalTypeNot,
+				Left: &Group{
+					Logic: LogicalTypeNot,
+					Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: 1.0}},
+				},
+			},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseLiteral(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input:  `name == "42"`,
+			Output: &Group{Expression: &Expression{Selector: "name", Operation: EQ, Match: "42"}},
+		},
+		{
+			Input:  `name != 'a || b'`,
+			Output: &Group{Expression: &Expression{Selector: "name", Operation: NE, Match: "a || b"}},
+		},
+		{
+			Input: `(name == "x(1)\n") && any(tags) == "\u0041"`,
+			Output: &Group{
+				Logic: LogicalTypeAnd,
+				Left:  &Group{Expression: &Expression{Selector: "name", Operation: EQ, Match: "x(1)\n"}},
+				Right: &Group{Expression: &Expression{Selector: "tags", Operation: EQ, Match: "A"}},
+			},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseLiteralBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: `name == "test`},
+		{Input: `name == "test\q"`},
+		{Input: `name > "42"`},
+		{Input: `name =~ "test"`},
+		{Input: `name == "test" extra`},
+		{Input: `name == "a" "b"`},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestParseNotBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: "!"},
+		{Input: "a == 1 !"},
+		{Input: "a ! b == 1"},
+		{Input: "a == 1 && !"},
+		{Input: "!()"},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestParseQuantifierBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: "any(tags == prod"},
+		{Input: "any(tags) prod"},
+		{Input: "tags any(tags) == 1"},
+		{Input: "any(tags == prod)"},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Values []interface{}
+		Output *Group
+	}{
+		{
+			Input:  `env in (prod, staging, "qa-1")`,
+			Values: []interface{}{"prod", "staging", "qa-1"},
+			Output: &Group{Expression: &Expression{Selector: "env", Operation: IN}},
+		},
+		{
+			Input:  "port not in (22, 23)",
+			Values: []interface{}{float64(22), float64(23)},
+			Output: &Group{Expression: &Expression{Selector: "port", Operation: NOTIN}},
+		},
+		{
+			Input:  "any(tags) in ('a,b')",
+			Values: []interface{}{"a,b"},
+			Output: &Group{Expression: &Expression{Selector: "tags", Operation: IN}},
+		},
+		{
+			Input:  "(env in ())",
+			Values: []interface{}{},
+			Output: &Group{Expression: &Expression{Selector: "env", Operation: IN}},
+		},
+	}
+	for i, c := range cs {
+		list := newList()
+		for _, x := range c.Values {
+			list.add(x)
+		}
+		c.Output.Expression.Match = list
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseStringOperations(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input:  `path ^= "/api"`,
+			Output: &Group{Expression: &Expression{Selector: "path", Operation: PREFIX, Match: "/api"}},
+		},
+		{
+			Input:  "path $= .json",
+			Output: &Group{Expression: &Expression{Selector: "path", Operation: SUFFIX, Match: ".json"}},
+		},
+		{
+			Input:  "code *= 404",
+			Output: &Group{Expression: &Expression{Selector: "code", Operation: CONTAINS, Match: "404"}},
+		},
+		{
+			Input:  `name ==i "Bob"`,
+			Output: &Group{Expression: &Expression{Selector: "name", Operation: EQI, Match: "Bob"}},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseGlob(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input:  `host ~= "web-*.eu-?.internal"`,
+			Output: &Group{Expression: &Expression{Selector: "host", Operation: GLOB, Match: regexp.MustCompile(`^web-[^/]*\.eu-[^/]\.internal$`)}},
+		},
+		{
+			Input:  "file ~= **/*.yaml",
+			Output: &Group{Expression: &Expression{Selector: "file", Operation: GLOB, Match: regexp.MustCompile(`^(?:.*/)?[^/]*\.yaml$`)}},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseGlobBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: "host ~= web-[0-9"},
+		{Input: `host ~= "web-[]"`},
+		{Input: `host ~= "web-\\"`},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestParsePresence(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input:  "exists(user.email)",
+			Output: &Group{Expression: &Expression{Selector: "user.email", Operation: NE, Match: Null{}}},
+		},
+		{
+			Input:  "!missing(name)",
+			Output: &Group{Logic: LogicalTypeNot, Left: &Group{Expression: &Expression{Selector: "name", Operation: EQ, Match: Null{}}}},
+		},
+		{
+			Input:  "name == null",
+			Output: &Group{Expression: &Expression{Selector: "name", Operation: EQ, Match: Null{}}},
+		},
+		{
+			Input:  `name != "null"`,
+			Output: &Group{Expression: &Expression{Selector: "name", Operation: NE, Match: "null"}},
+		},
 	}
 	for i, c := range cs {
 		checkLexParse(t, i, c.Input, c.Output)
 	}
 }
 
+func TestParsePresenceBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: "exists()"},
+		{Input: "exists(a"},
+		{Input: "exists(a) == 1"},
+		{Input: "any(exists(a))"},
+		{Input: "a > null"},
+		{Input: "a in (1, null)"},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestParseBoolean(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input:  "enabled == true",
+			Output: &Group{Expression: &Expression{Selector: "enabled", Operation: EQ, Match: true}},
+		},
+		{
+			Input:  "enabled != false",
+			Output: &Group{Expression: &Expression{Selector: "enabled", Operation: NE, Match: false}},
+		},
+		{
+			Input:  `enabled == "true"`,
+			Output: &Group{Expression: &Expression{Selector: "enabled", Operation: EQ, Match: "true"}},
+		},
+		{
+			Input: "enabled && count > 3",
+			Output: &Group{
+				Logic: LogicalTypeAnd,
+				Left:  &Group{Expression: &Expression{Selector: "enabled", Operation: TRUTHY}},
+				Right: &Group{Expression: &Expression{Selector: "count", Operation: GT, Match: 3.0}},
+			},
+		},
+		{
+			Input: "!(user.admin) || all(flags)",
+			Output: &Group{
+				Logic: LogicalTypeOr,
+				Left:  &Group{Logic: LogicalTypeNot, Left: &Group{Expression: &Expression{Selector: "user.admin", Operation: TRUTHY}}},
+				Right: &Group{Expression: &Expression{Selector: "flags", Quantifier: QuantifierAll, Operation: TRUTHY}},
+			},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseBooleanBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: "enabled > true"},
+		{Input: "enabled ^= false"},
+		{Input: "enabled =~ true"},
+		{Input: "1 && enabled"},
+		{Input: "a b && enabled"},
+		{Input: "enabled | debug"},
+		{Input: "any(flags"},
+		{Input: "any(flags && a"},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input:  "age > 15m",
+			Output: &Group{Expression: &Expression{Selector: "age", Operation: GT, Match: 15 * time.Minute}},
+		},
+		{
+			Input:  "latency <= 250ms",
+			Output: &Group{Expression: &Expression{Selector: "latency", Operation: LTE, Match: 250 * time.Millisecond}},
+		},
+		{
+			Input:  "created >= 2026-01-01T00:00:00Z",
+			Output: &Group{Expression: &Expression{Selector: "created", Operation: GTE, Match: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		},
+		{
+			Input:  "version ^= 1h",
+			Output: &Group{Expression: &Expression{Selector: "version", Operation: PREFIX, Match: "1h"}},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseTimeBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: "age > 15x"},
+		{Input: "created >= 2026-01-01"},
+		{Input: "created =~ 2026-01-01T00:00:00Z"},
+		{Input: "age > 99999999999999h"},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input:  "end_time > $start_time",
+			Output: &Group{Expression: &Expression{Selector: "end_time", Operation: GT, Match: Reference("start_time")}},
+		},
+		{
+			Input:  "owner == $request.user",
+			Output: &Group{Expression: &Expression{Selector: "owner", Operation: EQ, Match: Reference("request.user")}},
+		},
+		{
+			Input:  "path ^= $roots[0]",
+			Output: &Group{Expression: &Expression{Selector: "path", Operation: PREFIX, Match: Reference("roots[0]")}},
+		},
+		{
+			Input: "retries+1 >= $max_retries",
+			Output: &Group{Expression: &Expression{Operation: GTE, Match: Reference("max_retries"), Value: &Value{
+				Arithmetic: ArithmeticTypeAdd, Left: &Value{Selector: "retries"}, Right: &Value{Number: 1},
+			}}},
+		},
+		{
+			Input: "retries >= $max_retries - 1",
+			Output: &Group{Expression: &Expression{Selector: "retries", Operation: GTE, Match: &Value{
+				Arithmetic: ArithmeticTypeSubtract, Left: &Value{Selector: "max_retries"}, Right: &Value{Number: 1},
+			}}},
+		},
+		{
+			Input: "(a + 1) > $b",
+			Output: &Group{Expression: &Expression{Operation: GT, Match: Reference("b"), Value: &Value{
+				Arithmetic: ArithmeticTypeAdd, Left: &Value{Selector: "a"}, Right: &Value{Number: 1},
+			}}},
+		},
+		{
+			Input: "name == lower($a)",
+			Output: &Group{Expression: &Expression{Selector: "name", Operation: EQ, Match: &Value{
+				Call: &Call{Name: "lower", Arguments: []*Value{{Selector: "a"}}, function: builtins["lower"]},
+			}}},
+		},
+		{
+			Input: "name == lower(Admin)",
+			Output: &Group{Expression: &Expression{Selector: "name", Operation: EQ, Match: &Value{
+				Call: &Call{Name: "lower", Arguments: []*Value{{Literal: "Admin"}}, function: builtins["lower"]},
+			}}},
+		},
+		{
+			Input: "x > -$a % 2",
+			Output: &Group{Expression: &Expression{Selector: "x", Operation: GT, Match: &Value{
+				Arithmetic: ArithmeticTypeModulo, Left: &Value{Arithmetic: ArithmeticTypeNegate, Left: &Value{Selector: "a"}}, Right: &Value{Number: 2},
+			}}},
+		},
+		{
+			Input:  "owner == request.user",
+			Output: &Group{Expression: &Expression{Selector: "owner", Operation: EQ, Match: "request.user"}},
+		},
+		{
+			Input:  `owner == "$requester"`,
+			Output: &Group{Expression: &Expression{Selector: "owner", Operation: EQ, Match: "$requester"}},
+		},
+		{
+			Input:  "price == $5",
+			Output: &Group{Expression: &Expression{Selector: "price", Operation: EQ, Match: "$5"}},
+		},
+		{
+			Input:  "owner == -requester",
+			Output: &Group{Expression: &Expression{Selector: "owner", Operation: EQ, Match: "-requester"}},
+		},
+		{
+			Input:  "owner != null",
+			Output: &Group{Expression: &Expression{Selector: "owner", Operation: NE, Match: Null{}}},
+		},
+		{
+			Input:  "owner ~= admin",
+			Output: &Group{Expression: &Expression{Selector: "owner", Operation: GLOB, Match: regexp.MustCompile("^admin$")}},
+		},
+		{
+			Input:  "count >= inf",
+			Output: &Group{Expression: &Expression{Selector: "count", Operation: GTE, Match: math.Inf(1)}},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseReferenceBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: "end_time > null"},
+		{Input: "end_time > start_time"},
+		{Input: "end_time > $1start"},
+		{Input: "retries >= max_retries - 1"},
+		{Input: "x > -(a) * 2"},
+		{Input: "x > abs(a - 1)"},
+		{Input: "x == lower(a) + 1"},
+		{Input: "owner =~ requester"},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestParseArithmetic(t *testing.T) {
+	used, total := &Value{Selector: "used"}, &Value{Selector: "total"}
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input: "used / total * 100 > 90",
+			Output: &Group{Expression: &Expression{Operation: GT, Match: float64(90), Value: &Value{
+				Arithmetic: ArithmeticTypeMultiply,
+				Left:       &Value{Arithmetic: ArithmeticTypeDivide, Left: used, Right: total},
+				Right:      &Value{Number: 100},
+			}}},
+		},
+		{
+			Input: "used + total * 2 >= 10",
+			Output: &Group{Expression: &Expression{Operation: GTE, Match: float64(10), Value: &Value{
+				Arithmetic: ArithmeticTypeAdd,
+				Left:       used,
+				Right:      &Value{Arithmetic: ArithmeticTypeMultiply, Left: total, Right: &Value{Number: 2}},
+			}}},
+		},
+		{
+			Input: "(used + total) % 2 == 1",
+			Output: &Group{Expression: &Expression{Operation: EQ, Match: float64(1), Value: &Value{
+				Arithmetic: ArithmeticTypeModulo,
+				Left:       &Value{Arithmetic: ArithmeticTypeAdd, Left: used, Right: total},
+				Right:      &Value{Number: 2},
+			}}},
+		},
+		{
+			Input: "used - total - 1 < 0",
+			Output: &Group{Expression: &Expression{Operation: LT, Match: float64(0), Value: &Value{
+				Arithmetic: ArithmeticTypeSubtract,
+				Left:       &Value{Arithmetic: ArithmeticTypeSubtract, Left: used, Right: total},
+				Right:      &Value{Number: 1},
+			}}},
+		},
+		{
+			Input:  "-used != 1",
+			Output: &Group{Expression: &Expression{Operation: NE, Match: "1", Value: &Value{Arithmetic: ArithmeticTypeNegate, Left: used}}},
+		},
+		{
+			Input:  "-(used - 1) <= 0",
+			Output: &Group{Expression: &Expression{Operation: LTE, Match: float64(0), Value: &Value{Arithmetic: ArithmeticTypeNegate, Left: &Value{Arithmetic: ArithmeticTypeSubtract, Left: used, Right: &Value{Number: 1}}}}},
+		},
+		{
+			Input:  "used > $total * 0.5",
+			Output: &Group{Expression: &Expression{Selector: "used", Operation: GT, Match: &Value{Arithmetic: ArithmeticTypeMultiply, Left: total, Right: &Value{Number: 0.5}}}},
+		},
+		{
+			Input:  "used > (60 * 60 - 600) / -(2)",
+			Output: &Group{Expression: &Expression{Selector: "used", Operation: GT, Match: float64(-1500)}},
+		},
+		{
+			Input:  "(used) > 1",
+			Output: &Group{Expression: &Expression{Selector: "used", Operation: GT, Match: float64(1)}},
+		},
+		{
+			Input:  "(used - 1)",
+			Output: &Group{Expression: &Expression{Operation: TRUTHY, Value: &Value{Arithmetic: ArithmeticTypeSubtract, Left: used, Right: &Value{Number: 1}}}},
+		},
+		{
+			Input: "used/total*100 > 90",
+			Output: &Group{Expression: &Expression{Operation: GT, Match: float64(90), Value: &Value{
+				Arithmetic: ArithmeticTypeMultiply,
+				Left:       &Value{Arithmetic: ArithmeticTypeDivide, Left: used, Right: total},
+				Right:      &Value{Number: 100},
+			}}},
+		},
+		{
+			Input: "retries+1 >= 3",
+			Output: &Group{Expression: &Expression{Operation: GTE, Match: float64(3), Value: &Value{
+				Arithmetic: ArithmeticTypeAdd, Left: &Value{Selector: "retries"}, Right: &Value{Number: 1},
+			}}},
+		},
+		{
+			Input:  "used == 2+2*3",
+			Output: &Group{Expression: &Expression{Selector: "used", Operation: EQ, Match: float64(8)}},
+		},
+		{
+			Input: "used > $total/2",
+			Output: &Group{Expression: &Expression{Selector: "used", Operation: GT, Match: &Value{
+				Arithmetic: ArithmeticTypeDivide, Left: total, Right: &Value{Number: 2},
+			}}},
+		},
+		{
+			Input: "used ~= 2*3 || used == 50% || used == a/b",
+			Output: &Group{
+				Logic: LogicalTypeOr,
+				Left: &Group{
+					Logic: LogicalTypeOr,
+					Left:  &Group{Expression: &Expression{Selector: "used", Operation: GLOB, Match: regexp.MustCompile(`^2[^/]*3$`)}},
+					Right: &Group{Expression: &Expression{Selector: "used", Operation: EQ, Match: "50%"}},
+				},
+				Right: &Group{Expression: &Expression{Selector: "used", Operation: EQ, Match: "a/b"}},
+			},
+		},
+		{
+			Input:  "used-total == 1",
+			Output: &Group{Expression: &Expression{Selector: "used-total", Operation: EQ, Match: float64(1)}},
+		},
+		{
+			Input:  "path == /usr/bin",
+			Output: &Group{Expression: &Expression{Selector: "path", Operation: EQ, Match: "/usr/bin"}},
+		},
+		{
+			Input: "(a == 1 || b == 2) && used * 2 != 1",
+			Output: &Group{
+				Logic: LogicalTypeAnd,
+				Left: &Group{
+					Logic: LogicalTypeOr,
+					Left:  &Group{Expression: &Expression{Selector: "a", Operation: EQ, Match: float64(1)}},
+					Right: &Group{Expression: &Expression{Selector: "b", Operation: EQ, Match: float64(2)}},
+				},
+				Right: &Group{Expression: &Expression{Operation: NE, Match: "1", Value: &Value{
+					Arithmetic: ArithmeticTypeMultiply, Left: used, Right: &Value{Number: 2},
+				}}},
+			},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseArithmeticBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: "used + > 1"},
+		{Input: "used + 1 >"},
+		{Input: "used + \"a\" > 1"},
+		{Input: "used + 1h > 1"},
+		{Input: "used > 1 / 0"},
+		{Input: "used > 1 % (1 - 1)"},
+		{Input: "used =~ 1 + 1"},
+		{Input: "used ^= total + 1"},
+		{Input: "used > (total + 1"},
+		{Input: "(used == 1) * 2 > 1"},
+		{Input: "all(used) + 1 > 1"},
+		{Input: "used + 1 > 1 +"},
+		{Input: "used + #1 > 1"},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestParseFunction(t *testing.T) {
+	call := func(name string, arguments ...*Value) *Value {
+		return &Value{Call: &Call{Name: name, Arguments: arguments, function: builtins[name]}}
+	}
+	tags := &Value{Selector: "tags"}
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input:  `lower(user.name) == "admin"`,
+			Output: &Group{Expression: &Expression{Operation: EQ, Match: "admin", Value: call("lower", &Value{Selector: "user.name"})}},
+		},
+		{
+			Input:  "len(tags) > 2",
+			Output: &Group{Expression: &Expression{Operation: GT, Match: float64(2), Value: call("len", tags)}},
+		},
+		{
+			Input: "abs(used - total) < 0.5",
+			Output: &Group{Expression: &Expression{Operation: LT, Match: float64(0.5), Value: call("abs", &Value{
+				Arithmetic: ArithmeticTypeSubtract, Left: &Value{Selector: "used"}, Right: &Value{Selector: "total"},
+			})}},
+		},
+		{
+			Input:  "name ^= upper(trim($prefix))",
+			Output: &Group{Expression: &Expression{Selector: "name", Operation: PREFIX, Match: call("upper", call("trim", &Value{Selector: "prefix"}))}},
+		},
+		{
+			Input: "len(tags) * 2 != len($names) + 1",
+			Output: &Group{Expression: &Expression{
+				Operation: NE,
+				Match:     &Value{Arithmetic: ArithmeticTypeAdd, Left: call("len", &Value{Selector: "names"}), Right: &Value{Number: 1}},
+				Value:     &Value{Arithmetic: ArithmeticTypeMultiply, Left: call("len", tags), Right: &Value{Number: 2}},
+			}},
+		},
+		{
+			Input:  "len(tags)",
+			Output: &Group{Expression: &Expression{Operation: TRUTHY, Value: call("len", tags)}},
+		},
+		{
+			Input:  "(len(tags)) >= 1",
+			Output: &Group{Expression: &Expression{Operation: GTE, Match: float64(1), Value: call("len", tags)}},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseFunctionBad(t *testing.T) {
+	cs := []struct {
+		Input string
+		Code  ErrorCode
+	}{
+		{Input: "size(tags) > 1", Code: CodeUnknownFunction},
+		{Input: "len() > 1", Code: CodeInvalidArguments},
+		{Input: "len(tags, names) > 1", Code: CodeInvalidArguments},
+		{Input: "len(tags > 1", Code: CodeUnclosedGroup},
+		{Input: "lower(name == 1)", Code: CodeUnclosedGroup},
+		{Input: `abs("name") > 1`, Code: CodeInvalidArguments},
+		{Input: "lower(abs(x)) == a", Code: CodeInvalidArguments},
+		{Input: "lower(x) + 1 > 2", Code: CodeInvalidValue},
+		{Input: "-(lower(x)) < 2", Code: CodeInvalidValue},
+		{Input: "name =~ lower(x)", Code: CodeUnexpectedToken},
+		{Input: "name ~= lower(x)", Code: CodeUnexpectedToken},
+		{Input: "name in lower(x)", Code: CodeUnexpectedToken},
+		{Input: "name *= len(x) + 1", Code: CodeUnexpectedToken},
+		{Input: "len (tags) > 1", Code: CodeUnexpectedToken},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		var e *ParseError
+		if assert.True(t, errors.As(err, &e), "case %d, input: %s expected a parse error", i, c.Input) {
+			assert.Equal(t, c.Code, e.Code, "case %d, input: %s, error: %s", i, c.Input, e)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input:  "version >= v1.12.0",
+			Output: &Group{Expression: &Expression{Selector: "version", Operation: GTE, Match: Version{Major: 1, Minor: 12}}},
+		},
+		{
+			Input:  "agent < 2.0.0-rc.1",
+			Output: &Group{Expression: &Expression{Selector: "agent", Operation: LT, Match: Version{Major: 2, Prerelease: []string{"rc", "1"}}}},
+		},
+		{
+			Input:  "agent == 1.0.0+build.5",
+			Output: &Group{Expression: &Expression{Selector: "agent", Operation: EQ, Match: Version{Major: 1, Build: "build.5"}}},
+		},
+		{
+			Input:  "version ^= v1.12.0",
+			Output: &Group{Expression: &Expression{Selector: "version", Operation: PREFIX, Match: "v1.12.0"}},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseVersionBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: "version > 1.2.x"},
+		{Input: "version > 01.2.3"},
+		{Input: "version =~ 1.2.3"},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestParseNetwork(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Output *Group
+	}{
+		{
+			Input:  "src_ip in 10.0.0.0/8",
+			Output: &Group{Expression: &Expression{Selector: "src_ip", Operation: IN, Match: netip.MustParsePrefix("10.0.0.0/8")}},
+		},
+		{
+			Input:  "dst_ip == 192.168.1.5",
+			Output: &Group{Expression: &Expression{Selector: "dst_ip", Operation: EQ, Match: netip.MustParseAddr("192.168.1.5")}},
+		},
+		{
+			Input:  "ip not in 2001:db8::1/32",
+			Output: &Group{Expression: &Expression{Selector: "ip", Operation: NOTIN, Match: netip.MustParsePrefix("2001:db8::/32")}},
+		},
+		{
+			Input:  "ip != ::ffff:10.0.0.1",
+			Output: &Group{Expression: &Expression{Selector: "ip", Operation: NE, Match: netip.MustParseAddr("10.0.0.1")}},
+		},
+		{
+			Input:  "ip ^= 10.0.0.1",
+			Output: &Group{Expression: &Expression{Selector: "ip", Operation: PREFIX, Match: "10.0.0.1"}},
+		},
+	}
+	for i, c := range cs {
+		checkLexParse(t, i, c.Input, c.Output)
+	}
+}
+
+func TestParseNetworkBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: "ip in 10.0.0.1"},
+		{Input: "ip in 10.0.0.0/33"},
+		{Input: "ip in foo"},
+		{Input: "ip > 10.0.0.0/8"},
+		{Input: "ip =~ 10.0.0.1"},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestParseListBad(t *testing.T) {
+	cs := []struct {
+		Input string
+	}{
+		{Input: "env in (a, b"},
+		{Input: "env in (a,"},
+		{Input: "env in (a,,b)"},
+		{Input: "env in (a,)"},
+		{Input: "env in a"},
+		{Input: `env in ("a" "b")`},
+		{Input: "in (a, b)"},
+		{Input: "env == (a, b)"},
+		{Input: "env in (a) b"},
+	}
+	for i, c := range cs {
+		st, err := New(c.Input).Parse()
+		assert.Nil(t, st, "case %d, input: %s should have failed", i, c.Input)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	values := map[string][]interface{}{
+		"name":    {"test"},
+		"age":     {21},
+		"country": {"uk", "us"},
+		"enabled": {true},
+		"debug":   {"false"},
+		"count":   {0},
+		"used":    {45},
+		"total":   {"50"},
+		"retries": {2},
+		"limit":   {3},
+		"delta":   {-4},
+		"owner":   {"bob"},
+		"manager": {"Bob"},
+		"users":   {"bob", "alice"},
+		"uptime":  {30 * time.Minute},
+		"latency": {"250ms"},
+		"created": {"2026-03-01T10:00:00Z"},
+		"updated": {time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		"src":     {"10.1.2.3"},
+		"dst":     {net.ParseIP("192.168.1.5")},
+		"link":    {netip.MustParseAddr("fe80::1")},
+		"version": {"v1.12.3"},
+		"agent":   {"2.0.0-beta.4", Version{Major: 1, Minor: 9}},
+	}
+	cs := []struct {
+		Input    string
+		Expected bool
+	}{
+		{Input: "name == test", Expected: true},
+		{Input: "name != test"},
+		{Input: "age >= 21 && name == test", Expected: true},
+		{Input: "age > 21 || name == test", Expected: true},
+		{Input: "age > 21 && name == test"},
+		{Input: "country == us", Expected: true},
+		{Input: "name =~ /^te/ && country == uk", Expected: true},
+		{Input: "missing == 1"},
+		{Input: "(age < 18) || name == test", Expected: true},
+		{Input: "(age < 18) && name == test"},
+		{Input: "any(country) == uk", Expected: true},
+		{Input: "all(country) == uk"},
+		{Input: "all(country) =~ /^u/", Expected: true},
+		{Input: "all(missing) != 1"},
+		{Input: "((age < 18) || (age > 20)) && name == test", Expected: true},
+		{Input: "((age < 18) && (name == test)) || country == uk", Expected: true},
+		{Input: "((age < 18) && (name == test)) || country == fr"},
+		{Input: "name == test && (age > 30 || (country == uk && (age == 21)))", Expected: true},
+		{Input: "age == 21 || name == none && country == fr", Expected: true},
+		{Input: "name == none && country == fr || age == 21", Expected: true},
+		{Input: "(age == 21 || name == none) && country == fr"},
+		{Input: "!(age == 21 || name == none)"},
+		{Input: "!(age > 21 || name == none)", Expected: true},
+		{Input: "!name == none && !!age == 21", Expected: true},
+		{Input: "!all(country) == uk", Expected: true},
+		{Input: `name == "test"`, Expected: true},
+		{Input: `age == "21"`, Expected: true},
+		{Input: `age == "021"`},
+		{Input: `age == 021`, Expected: true},
+		{Input: `country == 'u' || name == "te(s)t"`},
+		{Input: "name in (prod, test)", Expected: true},
+		{Input: "name not in (prod, test)"},
+		{Input: "age in (18, 21) && name == test", Expected: true},
+		{Input: `age in ("21")`, Expected: true},
+		{Input: "all(country) in (uk, us, fr)", Expected: true},
+		{Input: "all(country) not in (uk)"},
+		{Input: "any(country) not in (uk)", Expected: true},
+		{Input: "name in ()"},
+		{Input: "missing not in (1)"},
+		{Input: `name ^= "te"`, Expected: true},
+		{Input: `name ^= "st"`},
+		{Input: "name $= st", Expected: true},
+		{Input: "name *= es && age *= 1", Expected: true},
+		{Input: `name ==i "TEST"`, Expected: true},
+		{Input: `name == "TEST"`},
+		{Input: `all(country) ^= u && !any(country) $= k`},
+		{Input: "name ~= t?st", Expected: true},
+		{Input: `all(country) ~= "u[ks]"`, Expected: true},
+		{Input: `name ~= "*es"`},
+		{Input: "exists(name) && missing(email)", Expected: true},
+		{Input: "exists(email) || missing(name)"},
+		{Input: "name != null && email == null", Expected: true},
+		{Input: "email != 5"},
+		{Input: "!(email == 5)"},
+		{Input: "!(email == 5) || name == test", Expected: true},
+		{Input: "!(email == 5 && name == none)", Expected: true},
+		{Input: "missing(email) || email == 5", Expected: true},
+		{Input: "enabled == true", Expected: true},
+		{Input: "enabled != false", Expected: true},
+		{Input: `enabled == "true"`, Expected: true},
+		{Input: "debug == false", Expected: true},
+		{Input: "name == true"},
+		{Input: "name != true", Expected: true},
+		{Input: "enabled in (true)", Expected: true},
+		{Input: "enabled && age > 18", Expected: true},
+		{Input: "debug || count"},
+		{Input: "!debug && !count && name", Expected: true},
+		{Input: "(enabled) && any(country)", Expected: true},
+		{Input: "email || !email"},
+		{Input: "uptime > 15m", Expected: true},
+		{Input: "uptime >= 1h"},
+		{Input: "uptime == 1800s", Expected: true},
+		{Input: "latency <= 250ms && latency > 0.1s", Expected: true},
+		{Input: "created >= 2026-01-01T00:00:00Z", Expected: true},
+		{Input: "created < 2026-03-01T11:00:00+01:00"},
+		{Input: "updated == 2026-01-01T01:00:00+01:00", Expected: true},
+		{Input: "updated != 2026-01-01T00:00:00Z"},
+		{Input: "name > 1m"},
+		{Input: "src in 10.0.0.0/8", Expected: true},
+		{Input: "src not in 10.0.0.0/8"},
+		{Input: "dst == 192.168.1.5 && dst in 192.168.0.0/16", Expected: true},
+		{Input: "dst != 192.168.1.5"},
+		{Input: "dst > 192.168.1.4 && dst < 192.168.1.6", Expected: true},
+		{Input: "link in fe80::/10 && link not in 10.0.0.0/8", Expected: true},
+		{Input: "src in (192.168.0.0/16, 10.1.2.3)", Expected: true},
+		{Input: "dst in (192.168.0.0/16, 10.1.2.3)", Expected: true},
+		{Input: "link in (10.0.0.0/8, ::1)"},
+		{Input: "name in 10.0.0.0/8"},
+		{Input: "name not in 10.0.0.0/8", Expected: true},
+		{Input: "version >= v1.12.0 && version < 1.13.0", Expected: true},
+		{Input: "version == 1.12.3+build.1", Expected: true},
+		{Input: "version > 1.12.3"},
+		{Input: "agent < 2.0.0-rc.1", Expected: true},
+		{Input: "all(agent) < 2.0.0-rc.1", Expected: true},
+		{Input: "all(agent) < 2.0.0-beta.4"},
+		{Input: "name > 1.0.0"},
+		{Input: "name != 1.0.0", Expected: true},
+		{Input: "used / total * 100 > 89", Expected: true},
+		{Input: "used / total * 100 > 90"},
+		{Input: "retries + 1 >= 3", Expected: true},
+		{Input: "(used + 5) / total == 1", Expected: true},
+		{Input: "used % 10 == 5 && -delta == 4", Expected: true},
+		{Input: "-(delta - 1) == 5", Expected: true},
+		{Input: "used > $total * 0.8", Expected: true},
+		{Input: "used > $total * 0.9"},
+		{Input: "used * -1 < (0)", Expected: true},
+		{Input: "used / count > 1"},
+		{Input: "!(used / count > 1)"},
+		{Input: "used + missing > 1 || used + name > 1"},
+		{Input: "used > $missing * 2 || !(used > $name * 2)"},
+		{Input: "(used - 45) || retries - 2", Expected: false},
+		{Input: "(used - 40)", Expected: true},
+		{Input: "country + 1 > 0"},
+		{Input: "created > $updated && updated < $created", Expected: true},
+		{Input: "updated >= $created"},
+		{Input: "uptime > $latency && total > $used", Expected: true},
+		{Input: "owner == $manager"},
+		{Input: "owner ==i $manager && owner != $name", Expected: true},
+		{Input: "manager $= $owner"},
+		{Input: "users == $owner", Expected: true},
+		{Input: "any(users) == $owner && all(users) != $name", Expected: true},
+		{Input: "owner == $users"},
+		{Input: "owner != $users"},
+		{Input: "age > $nobody"},
+		{Input: "!(age > $nobody)"},
+		{Input: "owner == $nobody || owner != $nobody"},
+		{Input: "name == test && owner != test", Expected: true},
+		{Input: "owner == manager || owner == $owner", Expected: true},
+		{Input: `name == "$owner"`},
+		{Input: "name > $owner"},
+		{Input: "enabled != $debug && dst != $src", Expected: true},
+		{Input: "retries+1 >= $retries && retries*2 < $used", Expected: true},
+		{Input: "retries >= $retries - 1 && retries < ($retries + 1) * 2", Expected: true},
+		{Input: "retries + 1 >= $limit && retries+1 == 1+2 && retries == $limit - 1", Expected: true},
+		{Input: "retries == 2+2 || retries*2 != $limit*2 - 2"},
+		{Input: "(retries + 1) > $retries && (retries + 1) > $used"},
+		{Input: "owner == lower($manager) && owner != lower(manager)", Expected: true},
+		{Input: "owner == lower(BOB) && upper(owner) == upper($manager)", Expected: true},
+		{Input: `lower(manager) == "bob" && upper(owner) == BOB`, Expected: true},
+		{Input: "len(users) == 2 && len(country) > 1 && len(missing) == 0", Expected: true},
+		{Input: "len(users) > 2"},
+		{Input: "abs(delta) < 5 && abs(delta) == 4", Expected: true},
+		{Input: "abs(used - total) / total <= 0.1", Expected: true},
+		{Input: "owner == lower($manager) && upper(manager) $= upper(trim($owner))", Expected: true},
+		{Input: "any(users) == lower($manager)", Expected: true},
+		{Input: "lower(users) == bob", Expected: true},
+		{Input: "abs(name) > 0 || abs(missing) < 0"},
+		{Input: "!(abs(missing) < 0)"},
+	}
+	fn := func(selector string) ([]interface{}, error) {
+		return values[selector], nil
+	}
+	for i, c := range cs {
+		matched, err := New(c.Input).Evaluate(fn)
+		assert.NoError(t, err, "case %d, input: %s should not have returned an error", i, c.Input)
+		assert.Equal(t, c.Expected, matched, "case %d, input: %s, expected: %t, got: %t", i, c.Input, c.Expected, matched)
+	}
+}
+
+func TestEvaluateBad(t *testing.T) {
+	matched, err := New("test > dsdsd").Evaluate(func(string) ([]interface{}, error) {
+		return nil, nil
+	})
+	assert.Error(t, err)
+	assert.False(t, matched)
+}
+
 func TestIsTokenOk(t *testing.T) {
 	cs := []struct {
 		ID     TokenID
@@ -0,0 +1,65 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import "net/netip"
+
+// newList creates an empty list
+func newList() *List {
+	return &List{
+		Values: make([]interface{}, 0),
+		set:    make(map[interface{}]bool),
+	}
+}
+
+// add appends the value to the list, the value must be a float64, string, bool, address
+// or network prefix
+func (l *List) add(v interface{}) {
+	l.Values = append(l.Values, v)
+	if prefix, found := v.(netip.Prefix); found {
+		l.prefixes = append(l.prefixes, prefix)
+		return
+	}
+	l.set[v] = true
+}
+
+// Contains checks if the value is a member of the list, the value is coerced in the same
+// way as the equality operation so a numeric string matches a numeric member, an address
+// is also a member when a prefix in the list contains it
+func (l *List) Contains(value interface{}) bool {
+	if v, found := toFloat(value); found && l.set[v] {
+		return true
+	}
+	if v, found := toString(value); found && l.set[v] {
+		return true
+	}
+	if v, found := toBool(value); found && l.set[v] {
+		return true
+	}
+	if v, found := toAddr(value); found {
+		if l.set[v] {
+			return true
+		}
+		for _, x := range l.prefixes {
+			if x.Contains(v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListContains(t *testing.T) {
+	list := newList()
+	list.add("prod")
+	list.add(float64(22))
+	cs := []struct {
+		Value    interface{}
+		Expected bool
+	}{
+		{Value: "prod", Expected: true},
+		{Value: []byte("prod"), Expected: true},
+		{Value: stringer("prod"), Expected: true},
+		{Value: 22, Expected: true},
+		{Value: uint16(22), Expected: true},
+		{Value: "22", Expected: true},
+		{Value: "staging"},
+		{Value: 23},
+		{Value: nil},
+	}
+	for i, c := range cs {
+		assert.Equal(t, c.Expected, list.Contains(c.Value), "case %d, value: %v", i, c.Value)
+	}
+}
+
+func TestListContainsNetwork(t *testing.T) {
+	list := newList()
+	list.add(netip.MustParsePrefix("10.0.0.0/8"))
+	list.add(netip.MustParseAddr("192.168.1.1"))
+	cs := []struct {
+		Value    interface{}
+		Expected bool
+	}{
+		{Value: "10.1.2.3", Expected: true},
+		{Value: net.ParseIP("10.1.2.3"), Expected: true},
+		{Value: netip.MustParseAddr("::ffff:10.1.2.3"), Expected: true},
+		{Value: "192.168.1.1", Expected: true},
+		{Value: "192.168.1.2"},
+		{Value: "test"},
+	}
+	for i, c := range cs {
+		assert.Equal(t, c.Expected, list.Contains(c.Value), "case %d, value: %v", i, c.Value)
+	}
+}
+
+func BenchmarkListContains(b *testing.B) {
+	list := newList()
+	for i := 0; i < 1000; i++ {
+		list.add(fmt.Sprintf("value-%d", i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		list.Contains("value-999")
+	}
+}
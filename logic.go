@@ -17,17 +17,40 @@ limitations under the License.
 package lex
 
 const (
+	// LogicalTypeNone indicates no logical operation, i.e. the group is an expression
+	LogicalTypeNone LogicType = 0
 	// LogicalTypeAnd indicates a AND operation
 	LogicalTypeAnd LogicType = 1
 	// LogicalTypeOr indicates a OR operation
-	LogicalTypeOr LogicType = 0
+	LogicalTypeOr LogicType = 2
+	// LogicalTypeNot indicates a negation of the left group
+	LogicalTypeNot LogicType = 3
 )
 
 // String returns a string representaton of the logical operation
 func (l *LogicType) String() string {
-	if *l == LogicalTypeAnd {
+	switch *l {
+	case LogicalTypeAnd:
 		return "&&"
+	case LogicalTypeOr:
+		return "||"
+	case LogicalTypeNot:
+		return "!"
 	}
 
-	return "||"
+	return "none"
+}
+
+// getLogic converts the token id to the logical operation
+func getLogic(id TokenID) LogicType {
+	switch id {
+	case LogicalAnd:
+		return LogicalTypeAnd
+	case LogicalOr:
+		return LogicalTypeOr
+	case LogicalNot:
+		return LogicalTypeNot
+	}
+
+	return LogicalTypeNone
 }
@@ -33,6 +33,24 @@ func (o *OperationID) String() string {
 		return "<="
 	case LIKE:
 		return "=~"
+	case IN:
+		return "in"
+	case NOTIN:
+		return "not in"
+	case PREFIX:
+		return "^="
+	case SUFFIX:
+		return "$="
+	case CONTAINS:
+		return "*="
+	case EQI:
+		return "==i"
+	case GLOB:
+		return "~="
+	case TRUTHY:
+		return "truthy"
+	case CUSTOM:
+		return "custom"
 	}
 
 	return "unknown"
@@ -55,6 +73,22 @@ func getOperation(id TokenID) OperationID {
 		return LTE
 	case LogicalRegex:
 		return LIKE
+	case LogicalIn:
+		return IN
+	case LogicalNotIn:
+		return NOTIN
+	case LogicalStartsWith:
+		return PREFIX
+	case LogicalEndsWith:
+		return SUFFIX
+	case LogicalContains:
+		return CONTAINS
+	case LogicalEqualFold:
+		return EQI
+	case LogicalGlob:
+		return GLOB
+	case LogicalCustom:
+		return CUSTOM
 	}
 
 	return NA
@@ -34,6 +34,14 @@ func TestOperationString(t *testing.T) {
 		{ID: GTE, Expected: ">="},
 		{ID: LTE, Expected: "<="},
 		{ID: LIKE, Expected: "=~"},
+		{ID: IN, Expected: "in"},
+		{ID: NOTIN, Expected: "not in"},
+		{ID: PREFIX, Expected: "^="},
+		{ID: SUFFIX, Expected: "$="},
+		{ID: CONTAINS, Expected: "*="},
+		{ID: EQI, Expected: "==i"},
+		{ID: GLOB, Expected: "~="},
+		{ID: TRUTHY, Expected: "truthy"},
 		{ID: NA, Expected: "unknown"},
 	}
 	for _, c := range cs {
@@ -53,6 +61,13 @@ func TestGetOperation(t *testing.T) {
 		{ID: LogicalLessThan, Expected: LT},
 		{ID: LogicalLessThanOrEqual, Expected: LTE},
 		{ID: LogicalRegex, Expected: LIKE},
+		{ID: LogicalIn, Expected: IN},
+		{ID: LogicalNotIn, Expected: NOTIN},
+		{ID: LogicalStartsWith, Expected: PREFIX},
+		{ID: LogicalEndsWith, Expected: SUFFIX},
+		{ID: LogicalContains, Expected: CONTAINS},
+		{ID: LogicalEqualFold, Expected: EQI},
+		{ID: LogicalGlob, Expected: GLOB},
 		{ID: Unknown, Expected: NA},
 	}
 	for _, c := range cs {
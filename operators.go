@@ -0,0 +1,135 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// symbols are the characters a symbolic operation can be made of
+const symbols = "~!@#$%^*=<>?:+/"
+
+// operations are the symbols and words of the built-in operations, which cannot be registered
+var operations = map[string]bool{
+	"==": true, "=": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true, "=~": true,
+	"^=": true, "$=": true, "*=": true, "~=": true, "==i": true, "!": true, "+": true, "*": true, "/": true,
+	"%": true, "in": true, "not": true, "true": true, "false": true, "null": true,
+}
+
+// NewOperatorRegistry creates an empty registry of operations
+func NewOperatorRegistry() *OperatorRegistry {
+	return &OperatorRegistry{operators: make(map[string]*Operator)}
+}
+
+// WithOperators permits the operations in the registry to be used in the expression, along
+// with the built-in operations
+func WithOperators(r *OperatorRegistry) Option {
+	return func(l *Lexer) {
+		l.operators = r
+	}
+}
+
+// Register adds the operation to the registry, the symbol must be a word or made of the
+// symbols ~!@#$%^*=<>?:+/ and cannot be a built-in operation, or the start of one as the
+// registered operations are matched first i.e. '~' would hide '~='
+func (r *OperatorRegistry) Register(op Operator) error {
+	switch {
+	case op.Symbol == "" || !functionName.MatchString(op.Symbol) && strings.Trim(op.Symbol, symbols) != "":
+		return fmt.Errorf("operator: '%s' is not a valid symbol", op.Symbol)
+	case isBuiltin(op.Symbol):
+		return fmt.Errorf("operator: '%s' is a built-in operation", op.Symbol)
+	case op.Evaluate == nil:
+		return fmt.Errorf("operator: '%s' has no evaluation", op.Symbol)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, found := r.operators[op.Symbol]; found {
+		return fmt.Errorf("operator: '%s' is already registered", op.Symbol)
+	}
+	r.operators[op.Symbol] = &op
+
+	return nil
+}
+
+// isBuiltin checks if the symbol is a built-in operation or reserved word, or the start of a
+// built-in symbolic operation
+func isBuiltin(symbol string) bool {
+	if operations[symbol] || reserved[symbol] {
+		return true
+	}
+	if functionName.MatchString(symbol) {
+		return false
+	}
+	for x := range operations {
+		if strings.HasPrefix(x, symbol) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookup returns the operation registered under the symbol
+func (r *OperatorRegistry) lookup(symbol string) (*Operator, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	op, found := r.operators[symbol]
+
+	return op, found
+}
+
+// sorted returns the operations in the order the symbols are matched, by precedence and
+// then the longest symbol
+func (r *OperatorRegistry) sorted() []*Operator {
+	if r == nil {
+		return nil
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	list := make([]*Operator, 0, len(r.operators))
+	for _, x := range r.operators {
+		list = append(list, x)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		switch {
+		case list[i].Precedence != list[j].Precedence:
+			return list[i].Precedence > list[j].Precedence
+		case len(list[i].Symbol) != len(list[j].Symbol):
+			return len(list[i].Symbol) > len(list[j].Symbol)
+		}
+		return list[i].Symbol < list[j].Symbol
+	})
+
+	return list
+}
+
+// apply is responsible for converting the value and match to the types the operation takes
+// and evaluating it, a value which cannot be converted does not satisfy the operation
+func (o *Operator) apply(value, match interface{}) (bool, error) {
+	left := o.Left.convert([]interface{}{value})
+	right := o.Right.convert([]interface{}{match})
+	if len(left) == 0 || len(right) == 0 {
+		return false, nil
+	}
+
+	return o.Evaluate(left[0], right[0])
+}
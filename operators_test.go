@@ -0,0 +1,124 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestOperators creates a registry with a fuzzy match and a geo-fence operation
+func newTestOperators(t *testing.T) *OperatorRegistry {
+	fences := map[string][2]float64{"london": {51.5, -0.1}}
+	r := NewOperatorRegistry()
+	assert.NoError(t, r.Register(Operator{
+		Symbol: "~~",
+		Left:   ValueTypeString,
+		Right:  ValueTypeString,
+		Evaluate: func(value, match interface{}) (bool, error) {
+			return strings.Contains(strings.ToLower(value.(string)), strings.ToLower(match.(string))), nil
+		},
+	}))
+	assert.NoError(t, r.Register(Operator{
+		Symbol: "within",
+		Left:   ValueTypeAny,
+		Right:  ValueTypeString,
+		Evaluate: func(value, match interface{}) (bool, error) {
+			fence, found := fences[match.(string)]
+			if !found {
+				return false, errors.New("unknown fence")
+			}
+			point, found := value.([2]float64)
+			if !found {
+				return false, nil
+			}
+			return math.Hypot(point[0]-fence[0], point[1]-fence[1]) < 1, nil
+		},
+	}))
+	assert.NoError(t, r.Register(Operator{
+		Symbol: "%%",
+		Left:   ValueTypeNumber,
+		Right:  ValueTypeNumber,
+		Evaluate: func(value, match interface{}) (bool, error) {
+			return math.Mod(value.(float64), match.(float64)) == 0, nil
+		},
+	}))
+
+	return r
+}
+
+func TestOperatorRegistry(t *testing.T) {
+	evaluate := func(interface{}, interface{}) (bool, error) {
+		return true, nil
+	}
+	r := newTestOperators(t)
+	assert.Error(t, r.Register(Operator{Symbol: "~~", Evaluate: evaluate}))
+	assert.Error(t, r.Register(Operator{Symbol: "==", Evaluate: evaluate}))
+	assert.Error(t, r.Register(Operator{Symbol: "in", Evaluate: evaluate}))
+	assert.Error(t, r.Register(Operator{Symbol: "any", Evaluate: evaluate}))
+	assert.Error(t, r.Register(Operator{Symbol: "", Evaluate: evaluate}))
+	assert.Error(t, r.Register(Operator{Symbol: "a-b", Evaluate: evaluate}))
+	assert.Error(t, r.Register(Operator{Symbol: "(~", Evaluate: evaluate}))
+	assert.Error(t, r.Register(Operator{Symbol: "~>"}))
+	for _, x := range []string{"~", "$", "^", "=", "!", "<", ">", "*", "==i"} {
+		assert.Error(t, r.Register(Operator{Symbol: x, Evaluate: evaluate}), "symbol: %s", x)
+	}
+	assert.NoError(t, r.Register(Operator{Symbol: "i", Evaluate: evaluate}))
+	assert.NoError(t, r.Register(Operator{Symbol: "~~>", Evaluate: evaluate}))
+	assert.NoError(t, r.Register(Operator{Symbol: "?", Precedence: 1, Evaluate: evaluate}))
+
+	var symbols []string
+	for _, x := range r.sorted() {
+		symbols = append(symbols, x.Symbol)
+	}
+	assert.Equal(t, []string{"?", "within", "~~>", "%%", "~~", "i"}, symbols)
+
+	op, found := r.lookup("within")
+	assert.True(t, found)
+	assert.Equal(t, "within", op.Symbol)
+	_, found = r.lookup("~>")
+	assert.False(t, found)
+
+	var empty *OperatorRegistry
+	assert.Empty(t, empty.sorted())
+	_, found = empty.lookup("~~")
+	assert.False(t, found)
+}
+
+func TestOperatorApply(t *testing.T) {
+	r := newTestOperators(t)
+	fuzzy, _ := r.lookup("~~")
+	matched, err := fuzzy.apply("Jonathan", "JON")
+	assert.NoError(t, err)
+	assert.True(t, matched)
+	matched, err = fuzzy.apply(struct{}{}, "jon")
+	assert.NoError(t, err)
+	assert.False(t, matched)
+
+	divisible, _ := r.lookup("%%")
+	matched, err = divisible.apply("12", float64(4))
+	assert.NoError(t, err)
+	assert.True(t, matched)
+
+	within, _ := r.lookup("within")
+	_, err = within.apply([2]float64{51.4, 0}, "paris")
+	assert.Error(t, err)
+}
@@ -21,44 +21,60 @@ import (
 	"strings"
 )
 
-// tokenizer
+// tokenizer extracts the tokens from the input on demand
 type tokenizer struct {
-	input      string       // the actual input
-	position   int          // the current position in the string
-	start      int          // the start of the cursor
-	tokenCh    TokenChannel // the channel to send the tokens
-	stopSignal bool         // an exit signal
+	input     string      // the actual input
+	position  int         // the current position in the string
+	start     int         // the start of the cursor
+	state     tokenFn     // the next state to run
+	tokens    []Token     // the tokens emitted and waiting to be consumed
+	head      int         // the index of the next token to consume
+	finished  bool        // indicates the end of stream has been emitted
+	brackets  []TokenID   // the groups and function calls opened and not yet closed
+	last      TokenID     // the last token emitted
+	operators []*Operator // the registered operations in the order they are matched
 }
 
 type tokenFn func(*tokenizer) tokenFn
 
-// newTokenizer creates a tokenizer and starts extract tokens from the input
-// sending along the channel
-func newTokenizer(input string) TokenChannel {
-	t := &tokenizer{
-		input:   input,
-		tokenCh: make(TokenChannel, 10),
+// newTokenizer creates a tokenizer for the input and the registered operations, the tokens
+// are extracted as they are requested via Next
+func newTokenizer(input string, operators []*Operator) *tokenizer {
+	return &tokenizer{
+		input:     input,
+		state:     insideEntry,
+		tokens:    make([]Token, 0, 4),
+		operators: operators,
 	}
+}
 
-	go func() {
-		// step: emit the entrance
-		t.emit(Entry)
-		// step: look and extract the symbols
-		for state := insideExpression; state != nil && !t.stopSignal; {
-			state = state(t)
+// Next returns the next token in the stream, or io.EOF once the EOF token has been consumed
+func (l *tokenizer) Next() (Token, error) {
+	for l.head >= len(l.tokens) {
+		l.tokens = l.tokens[:0]
+		l.head = 0
+		if l.state == nil {
+			if l.finished {
+				return Token{}, io.EOF
+			}
+			// step: end the token stream
+			l.finished = true
+			l.emit(EOF)
+			break
 		}
-		// step: end the token stream
-		t.emit(EOF)
-		// step: close these channel
-		close(t.tokenCh)
-	}()
+		l.state = l.state(l)
+	}
+	token := l.tokens[l.head]
+	l.head++
 
-	return t.tokenCh
+	return token, nil
 }
 
-// stop is responsible for stopping the parser from continuing
-func (l *tokenizer) stop() {
-	l.stopSignal = true
+// insideEntry emits the start of the token stream
+func insideEntry(l *tokenizer) tokenFn {
+	l.emit(Entry)
+
+	return insideExpression
 }
 
 func insideExpression(l *tokenizer) tokenFn {
@@ -67,6 +83,14 @@ func insideExpression(l *tokenizer) tokenFn {
 		l.emit(Expr)
 		return nil
 	}
+	// step: check for a registered operation i.e. name ~~ jon
+	if symbol := l.operator(); symbol != "" {
+		l.backup()
+		l.emit(Expr)
+		l.position += len(symbol)
+		l.emit(LogicalCustom)
+		return insideMatch
+	}
 	switch c {
 	case '(':
 		return insideLeftBracket
@@ -84,6 +108,50 @@ func insideExpression(l *tokenizer) tokenFn {
 		return insideEquality
 	case '!':
 		return insideInvertEquality
+	case '+', '/', '%':
+		// step: the operation need not be spaced after an operand i.e. retries+1
+		if l.operand() {
+			l.backup()
+			l.emitArithmetic(l.arithmeticOf(l.position))
+		}
+	case '*':
+		if l.peek() != '=' && l.operand() {
+			l.backup()
+			l.emitArithmetic(ArithmeticMultiply)
+			return insideExpression
+		}
+		return insideStringOperation
+	case '^', '$', '~':
+		return insideStringOperation
+	case ',':
+		// step: a comma separates the arguments of a function call i.e. f(a, b)
+		if l.calling() {
+			l.emitBefore(Expr)
+			l.emit(Separator)
+		}
+	case '"', '\'':
+		// step: a quoted string can be an argument of a function call i.e. f(a, "b")
+		if l.calling() && strings.TrimSpace(l.input[l.start:l.position-1]) == "" {
+			l.backup()
+			l.discard()
+			return insideQuoted
+		}
+	case '-':
+		// step: a minus opening a bracket at the start of an operand is a negation i.e. -(a + b)
+		if l.peek() == '(' && strings.TrimSpace(l.input[l.start:l.position-1]) == "" {
+			l.start = l.position - 1
+			l.emit(ArithmeticNegate)
+		}
+	case ' ', '\t', '\n':
+		if id := l.arithmetic(l.position); id != Unknown {
+			l.emitArithmetic(id)
+			return insideExpression
+		}
+		// step: check for a membership without a list i.e. ip in 10.0.0.0/8
+		if id, begin, end := membership(l.input[l.start:l.position]); id != Unknown && l.lookahead() != '(' {
+			l.emitMembership(id, begin, end)
+			return insideMatch
+		}
 	}
 
 	return insideExpression
@@ -91,12 +159,94 @@ func insideExpression(l *tokenizer) tokenFn {
 
 // insideMatch indicates we are in matching expression i.e. what were comparing to
 func insideMatch(l *tokenizer) tokenFn {
-	l.unless([]byte{'(', ')', '&', '|', '>', '<', '=', '!'})
-	l.emit(Match)
+	for l.peek() == ' ' || l.peek() == '\t' || l.peek() == '\n' {
+		l.ignore()
+	}
+	// step: check if the match is a quoted string or an arithmetic expression
+	switch c := l.peek(); {
+	case c == '"' || c == '\'':
+		l.discard()
+		return insideQuoted
+	case c == '(':
+		// step: the bracket opens an arithmetic expression i.e. x > (a + b) / 2
+		l.discard()
+		l.ignore()
+		l.emit(OpenGroup)
+		l.brackets = append(l.brackets, OpenGroup)
+		return insideExpression
+	case c == '-' && l.position+1 < len(l.input) && l.input[l.position+1] == '(':
+		l.discard()
+		l.ignore()
+		l.emit(ArithmeticNegate)
+		return insideMatch
+	}
+	// step: the match ends at an operation, or the arithmetic i.e. x > y * 2 or x == 2+2
+	for c := l.peek(); c != 0 && strings.IndexByte("()&|><=!", c) < 0; c = l.peek() {
+		if isSpace(c) && l.arithmetic(l.position+1) != Unknown || l.unspaced() != Unknown {
+			break
+		}
+		l.ignore()
+	}
+	// step: check if the match is a function call i.e. name == lower(other)
+	if name := strings.TrimSpace(l.input[l.start:l.position]); l.peek() == '(' && functionName.MatchString(name) {
+		l.start = l.position - len(name)
+		l.emit(FunctionCall)
+		l.ignore()
+		l.discard()
+		l.brackets = append(l.brackets, FunctionCall)
+		return insideExpression
+	}
+	if id := l.unspaced(); id != Unknown {
+		l.emitMatch(true)
+		l.ignore()
+		l.emit(id)
+		return insideExpression
+	}
+	l.emitMatch(true)
+
+	return insideExpression
+}
+
+// insideQuoted indicates we are inside a quoted string, the escape sequences are
+// left for the parser to decode
+func insideQuoted(l *tokenizer) tokenFn {
+	if !l.quoted() {
+		return nil
+	}
 
 	return insideExpression
 }
 
+// insideList indicates we are inside the values of a list i.e. (a, "b", 1)
+func insideList(l *tokenizer) tokenFn {
+	for l.peek() == ' ' || l.peek() == '\t' || l.peek() == '\n' {
+		l.ignore()
+	}
+	l.discard()
+
+	switch l.peek() {
+	case 0:
+		return nil
+	case '"', '\'':
+		if !l.quoted() {
+			return nil
+		}
+		return insideList
+	case ',':
+		l.ignore()
+		l.emit(Separator)
+		return insideList
+	case ')':
+		l.ignore()
+		l.emit(CloseList)
+		return insideExpression
+	}
+	l.unless([]byte{',', ')'})
+	l.emitMatch(false)
+
+	return insideList
+}
+
 func insideLessThan(l *tokenizer) tokenFn {
 	l.emitBefore(Expr)
 
@@ -119,7 +269,29 @@ func insideInvertEquality(l *tokenizer) tokenFn {
 
 		return insideMatch
 	}
-	l.backup()
+	l.emit(LogicalNot)
+
+	return insideExpression
+}
+
+// insideStringOperation checks for the string operations i.e. '^=', '$=', '*=' and '~='
+func insideStringOperation(l *tokenizer) tokenFn {
+	if l.peek() != '=' {
+		return insideExpression
+	}
+	l.emitBefore(Expr)
+	l.ignore()
+
+	switch l.input[l.position-2] {
+	case '^':
+		l.emit(LogicalStartsWith)
+	case '$':
+		l.emit(LogicalEndsWith)
+	case '~':
+		l.emit(LogicalGlob)
+	default:
+		l.emit(LogicalContains)
+	}
 
 	return insideMatch
 }
@@ -143,6 +315,12 @@ func insideEquality(l *tokenizer) tokenFn {
 	switch l.peek() {
 	case '=':
 		l.ignore()
+		// step: check for the case insensitive equality i.e. '==i'
+		if l.peek() == 'i' && (l.position+1 >= len(l.input) || strings.IndexByte(" \t\n\"'", l.input[l.position+1]) >= 0) {
+			l.ignore()
+			l.emit(LogicalEqualFold)
+			break
+		}
 		l.emit(LogicalEqual)
 	case '~':
 		l.ignore()
@@ -185,11 +363,101 @@ func insideRegex(l *tokenizer) tokenFn {
 }
 
 func insideLeftBracket(l *tokenizer) tokenFn {
+	// step: check if the bracket is opening a quantifier i.e. any(tags)
+	text := l.input[l.start : l.position-1]
+	switch strings.TrimSpace(text) {
+	case "any", "all":
+		l.emitBefore(Quantifier)
+		l.discard()
+
+		return insideQuantifier
+	case "exists":
+		l.discard()
+		return insidePresence(Exists)
+	case "missing":
+		l.discard()
+		return insidePresence(Missing)
+	}
+	// step: check if the bracket is opening a list i.e. env in (a, b)
+	if id, begin, end := membership(text); id != Unknown {
+		position := l.position
+		l.emitMembership(id, begin, end)
+		l.start, l.position = position-1, position
+		l.emit(OpenList)
+
+		return insideList
+	}
+	// step: check if the bracket is opening a function call i.e. lower(user.name)
+	if name := strings.TrimLeft(text, " \t\n\r"); functionName.MatchString(name) {
+		l.start += len(text) - len(name)
+		l.emitBefore(FunctionCall)
+		l.discard()
+		l.brackets = append(l.brackets, FunctionCall)
+
+		return insideExpression
+	}
+	l.emitBefore(Expr)
 	l.emit(OpenGroup)
+	l.brackets = append(l.brackets, OpenGroup)
 
 	return insideExpression
 }
 
+// membership checks if the text ends with a list operator i.e. 'in' or 'not in', returning
+// the token and the span of the operator in the text
+func membership(text string) (TokenID, int, int) {
+	isWord := func(s, word string) bool {
+		if !strings.HasSuffix(s, word) {
+			return false
+		}
+		n := len(s) - len(word)
+
+		return n == 0 || isSpace(s[n-1])
+	}
+	trimmed := strings.TrimRight(text, " \t\n\r")
+	if !isWord(trimmed, "in") {
+		return Unknown, 0, 0
+	}
+	before := strings.TrimRight(trimmed[:len(trimmed)-len("in")], " \t\n\r")
+	if isWord(before, "not") {
+		return LogicalNotIn, len(before) - len("not"), len(trimmed)
+	}
+
+	return LogicalIn, len(trimmed) - len("in"), len(trimmed)
+}
+
+// insideQuantifier indicates we are inside the brackets of a quantifier i.e. the selector
+func insideQuantifier(l *tokenizer) tokenFn {
+	l.unless([]byte{')'})
+	l.emit(Expr)
+	if l.peek() != ')' {
+		l.emit(Unknown)
+		return nil
+	}
+	l.ignore()
+	l.discard()
+
+	return insideExpression
+}
+
+// insidePresence indicates we are inside the brackets of a presence check i.e. exists(user.email),
+// the selector is emitted as the value of the token
+func insidePresence(id TokenID) tokenFn {
+	return func(l *tokenizer) tokenFn {
+		l.unless([]byte{')'})
+		if l.peek() != ')' {
+			l.emit(Expr)
+			l.emit(Unknown)
+			return nil
+		}
+		l.emit(id)
+		l.ignore()
+		l.discard()
+
+		return insideExpression
+	}
+}
+
 func insideRightBracket(l *tokenizer) tokenFn {
 	if l.previous() != ')' {
 		l.backup()
@@ -197,12 +465,18 @@ func insideRightBracket(l *tokenizer) tokenFn {
 		l.ignore()
 	}
 	l.emit(CloseGroup)
+	if n := len(l.brackets); n > 0 {
+		l.brackets = l.brackets[:n-1]
+	}
 
 	return insideExpression
 }
 
 func insideLogicalOr(l *tokenizer) tokenFn {
 	if l.peek() != '|' {
+		// step: a single '|' is not an operation
+		l.emitBefore(Expr)
+		l.emit(Unknown)
 		return insideExpression
 	}
 	// step: emit the logical OR
@@ -216,6 +490,9 @@ func insideLogicalOr(l *tokenizer) tokenFn {
 
 func insideLogicalAnd(l *tokenizer) tokenFn {
 	if l.peek() != '&' {
+		// step: a single '&' is not an operation
+		l.emitBefore(Expr)
+		l.emit(Unknown)
 		return insideExpression
 	}
 	// step: emit the logical AN
@@ -227,19 +504,180 @@ func insideLogicalAnd(l *tokenizer) tokenFn {
 	return insideExpression
 }
 
-// emit is responsible for emitting the token upstream
+// emitMembership emits the selector and the list operation found at the span in the text
+// from the start of the cursor, moving the cursor past the operation
+func (l *tokenizer) emitMembership(id TokenID, begin, end int) {
+	start := l.start
+	l.position = start + begin
+	l.emit(Expr)
+	l.start, l.position = start+begin, start+end
+	l.emit(id)
+}
+
+// arithmetic returns the arithmetic operation at the position in the input, the operation
+// must be surrounded by whitespace to tell it apart from a selector or value i.e. a-b
+func (l *tokenizer) arithmetic(i int) TokenID {
+	if i <= 0 || i >= len(l.input) || !isSpace(l.input[i-1]) {
+		return Unknown
+	}
+	if i+1 < len(l.input) && !isSpace(l.input[i+1]) {
+		return Unknown
+	}
+
+	return l.arithmeticOf(i)
+}
+
+// operand checks if the character consumed follows an operand, either a selector, a number
+// or a group, so an arithmetic operation need not be spaced i.e. used/total*100
+func (l *tokenizer) operand() bool {
+	return strings.TrimSpace(l.input[l.start:l.position-1]) != "" || l.last == CloseGroup
+}
+
+// unspaced returns the arithmetic operation at the position in a match which is not spaced,
+// the match must be a number or a selector named with a $ being compared, so paths, globs,
+// prefixes and versions are left as they are i.e. x == 2+2 or x > $y*2
+func (l *tokenizer) unspaced() TokenID {
+	i := l.position
+	if i+1 >= len(l.input) || isSpace(l.input[i+1]) || strings.IndexByte("+*/%", l.input[i]) < 0 {
+		return Unknown
+	}
+	if !isComparison(l.last) && l.last != LogicalCustom {
+		return Unknown
+	}
+	value := strings.TrimPrefix(strings.TrimSpace(l.input[l.start:i]), "-")
+	if found, _ := parseIfFloat(value); !found && !(strings.HasPrefix(value, "$") && bareSelector.MatchString(value[1:])) {
+		return Unknown
+	}
+
+	return l.arithmeticOf(i)
+}
+
+// arithmeticOf returns the arithmetic operation of the character at the position in the input
+func (l *tokenizer) arithmeticOf(i int) TokenID {
+	switch l.input[i] {
+	case '+':
+		return ArithmeticAdd
+	case '-':
+		return ArithmeticSubtract
+	case '*':
+		return ArithmeticMultiply
+	case '/':
+		return ArithmeticDivide
+	case '%':
+		return ArithmeticModulo
+	}
+
+	return Unknown
+}
+
+// emitArithmetic emits the operand before the position and the arithmetic operation at it
+func (l *tokenizer) emitArithmetic(id TokenID) {
+	l.emit(Expr)
+	l.discard()
+	l.ignore()
+	l.emit(id)
+}
+
+// operator returns the symbol of the registered operation at the character consumed, which
+// must follow a selector or a group. A word must be surrounded by whitespace i.e. a within b
+func (l *tokenizer) operator() string {
+	i := l.position - 1
+	if strings.TrimSpace(l.input[l.start:i]) == "" && l.last != Expr && l.last != CloseGroup {
+		return ""
+	}
+	for _, x := range l.operators {
+		if !strings.HasPrefix(l.input[i:], x.Symbol) {
+			continue
+		}
+		if end := i + len(x.Symbol); functionName.MatchString(x.Symbol) {
+			if !isSpace(l.input[i-1]) || end < len(l.input) && !isSpace(l.input[end]) {
+				continue
+			}
+		}
+		return x.Symbol
+	}
+
+	return ""
+}
+
+// calling checks if the innermost bracket opened is a function call
+func (l *tokenizer) calling() bool {
+	return len(l.brackets) > 0 && l.brackets[len(l.brackets)-1] == FunctionCall
+}
+
+// lookahead returns the next character which is not whitespace, without consuming it
+func (l *tokenizer) lookahead() byte {
+	for i := l.position; i < len(l.input); i++ {
+		if !isSpace(l.input[i]) {
+			return l.input[i]
+		}
+	}
+
+	return 0
+}
+
+// quoted consumes a quoted string and emits the literal, returning false if the input
+// ended before the closing quote
+func (l *tokenizer) quoted() bool {
+	quote, _ := l.next()
+	for {
+		c, err := l.next()
+		if err == io.EOF {
+			l.emit(Literal)
+			return false
+		}
+		switch c {
+		case '\\':
+			l.next()
+		case quote:
+			l.emit(Literal)
+			return true
+		}
+	}
+}
+
+// emit is responsible for queuing the token for the consumer
 func (l *tokenizer) emit(id TokenID) {
 	value := strings.TrimSpace(l.input[l.start:l.position])
 	if id == Expr && value == "" {
 		return
 	}
 
-	l.tokenCh <- Token{
+	l.tokens = append(l.tokens, Token{
 		ID:    id,
-		Value: strings.TrimSpace(value),
+		Value: value,
 		Start: l.start,
 		End:   l.position,
+	})
+	l.start = l.position
+	l.last = id
+}
+
+// emitMatch emits the value being matched, the words true and false are emitted as booleans,
+// the addresses and prefixes as such and when typed the durations, timestamps and versions
+// as such
+func (l *tokenizer) emitMatch(typed bool) {
+	value := strings.TrimSpace(l.input[l.start:l.position])
+	switch {
+	case value == "true" || value == "false":
+		l.emit(Boolean)
+	case typed && isDuration(value):
+		l.emit(Duration)
+	case typed && isTimestamp(value):
+		l.emit(Timestamp)
+	case typed && isVersion(value):
+		l.emit(SemVer)
+	case isAddress(value):
+		l.emit(IPAddress)
+	case isPrefix(value):
+		l.emit(CIDR)
+	default:
+		l.emit(Match)
 	}
+}
+
+// discard drops the input between the start of the cursor and the position
+func (l *tokenizer) discard() {
 	l.start = l.position
 }
 
@@ -307,7 +745,7 @@ func (l *tokenizer) unless(filter []byte) {
 
 // prev allows use to look at the previous char
 func (l *tokenizer) previous() byte {
-	if l.position == 0 {
+	if l.position < 2 {
 		return ' '
 	}
 
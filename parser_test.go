@@ -17,13 +17,14 @@ limitations under the License.
 package lex
 
 import (
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewTokenizer(t *testing.T) {
-	tk := newTokenizer("input")
+	tk := newTokenizer("input", nil)
 	assert.NotNil(t, tk)
 }
 
@@ -104,11 +105,7 @@ func TestParseTokensOK(t *testing.T) {
 		},
 	}
 	for i, x := range cs {
-		var index = 0
-		for item := range newTokenizer(x.Input) {
-			checkToken(t, i, index, x.Tokens, item)
-			index++
-		}
+		checkTokens(t, i, x.Input, x.Tokens)
 	}
 }
 
@@ -117,6 +114,14 @@ func TestParseTokensInvalid(t *testing.T) {
 		Input  string
 		Tokens []Token
 	}{
+		{
+			Input: ")",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: CloseGroup, Value: ")"},
+				{ID: EOF},
+			},
+		},
 		{
 			Input: "test)=1",
 			Tokens: []Token{
@@ -152,7 +157,8 @@ func TestParseTokensInvalid(t *testing.T) {
 			Input: "test&<=1",
 			Tokens: []Token{
 				{ID: Entry},
-				{ID: Expr, Value: "test&"},
+				{ID: Expr, Value: "test"},
+				{ID: Unknown, Value: "&"},
 				{ID: LogicalLessThanOrEqual, Value: "<="},
 				{ID: Match, Value: "1"},
 				{ID: EOF},
@@ -197,14 +203,761 @@ func TestParseTokensInvalid(t *testing.T) {
 		},
 	}
 	for i, x := range cs {
-		var index = 0
-		for item := range newTokenizer(x.Input) {
-			checkToken(t, i, index, x.Tokens, item)
-			index++
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensNot(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: "!(a == 1 || b != 2)",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: LogicalNot, Value: "!"},
+				{ID: OpenGroup, Value: "("},
+				{ID: Expr, Value: "a"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "1"},
+				{ID: LogicalOr, Value: "||"},
+				{ID: Expr, Value: "b"},
+				{ID: LogicalInvert, Value: "!="},
+				{ID: Match, Value: "2"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "a == 1 && ! !all(b) == 2",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "a"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "1"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: LogicalNot, Value: "!"},
+				{ID: LogicalNot, Value: "!"},
+				{ID: Quantifier, Value: "all"},
+				{ID: Expr, Value: "b"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "2"},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensLiteral(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: `name == "a || b"`,
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "name"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Literal, Value: `"a || b"`},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: `name != 'x(1)' && (name == "say \"hi\"")`,
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "name"},
+				{ID: LogicalInvert, Value: "!="},
+				{ID: Literal, Value: `'x(1)'`},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: OpenGroup, Value: "("},
+				{ID: Expr, Value: "name"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Literal, Value: `"say \"hi\""`},
+				{ID: CloseGroup, Value: ")"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: `name == "unterminated`,
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "name"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Literal, Value: `"unterminated`},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensLiteralPosition(t *testing.T) {
+	tk := newTokenizer(`a ==  "b"`, nil)
+	for {
+		token, err := tk.Next()
+		assert.NoError(t, err)
+		if token.ID == Literal {
+			assert.Equal(t, 6, token.Start)
+			assert.Equal(t, 9, token.End)
+			return
 		}
 	}
 }
 
+func TestParseTokensQuantifier(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: "any(tags) == prod",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Quantifier, Value: "any"},
+				{ID: Expr, Value: "tags"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "prod"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "name == test && all( ports )<1024",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "name"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "test"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Quantifier, Value: "all"},
+				{ID: Expr, Value: "ports"},
+				{ID: LogicalLessThan, Value: "<"},
+				{ID: Match, Value: "1024"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "(all(ports) > 1)",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: OpenGroup, Value: "("},
+				{ID: Quantifier, Value: "all"},
+				{ID: Expr, Value: "ports"},
+				{ID: LogicalGreaterThan, Value: ">"},
+				{ID: Match, Value: "1"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensList(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: `env in (prod, "qa-1")`,
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "env"},
+				{ID: LogicalIn, Value: "in"},
+				{ID: OpenList, Value: "("},
+				{ID: Match, Value: "prod"},
+				{ID: Separator, Value: ","},
+				{ID: Literal, Value: `"qa-1"`},
+				{ID: CloseList, Value: ")"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "(port not in(22,23)) && any(tags) in ()",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: OpenGroup, Value: "("},
+				{ID: Expr, Value: "port"},
+				{ID: LogicalNotIn, Value: "not in"},
+				{ID: OpenList, Value: "("},
+				{ID: Match, Value: "22"},
+				{ID: Separator, Value: ","},
+				{ID: Match, Value: "23"},
+				{ID: CloseList, Value: ")"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Quantifier, Value: "any"},
+				{ID: Expr, Value: "tags"},
+				{ID: LogicalIn, Value: "in"},
+				{ID: OpenList, Value: "("},
+				{ID: CloseList, Value: ")"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "login == 1 && index == 1",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "login"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "1"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "index"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "1"},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensStringOperations(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: `path ^= "/api" && path$=.json`,
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "path"},
+				{ID: LogicalStartsWith, Value: "^="},
+				{ID: Literal, Value: `"/api"`},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "path"},
+				{ID: LogicalEndsWith, Value: "$="},
+				{ID: Match, Value: ".json"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: `msg *= timeout || name ==i "Bob"`,
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "msg"},
+				{ID: LogicalContains, Value: "*="},
+				{ID: Match, Value: "timeout"},
+				{ID: LogicalOr, Value: "||"},
+				{ID: Expr, Value: "name"},
+				{ID: LogicalEqualFold, Value: "==i"},
+				{ID: Literal, Value: `"Bob"`},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: `host ~= "web-*" || file~=**/*.yaml`,
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "host"},
+				{ID: LogicalGlob, Value: "~="},
+				{ID: Literal, Value: `"web-*"`},
+				{ID: LogicalOr, Value: "||"},
+				{ID: Expr, Value: "file"},
+				{ID: LogicalGlob, Value: "~="},
+				{ID: Match, Value: "**/*.yaml"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "name==ibob && a*b == 1",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "name"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "ibob"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "a"},
+				{ID: ArithmeticMultiply, Value: "*"},
+				{ID: Expr, Value: "b"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "1"},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensPresence(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: "exists(user.email) && !missing( name )",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Exists, Value: "user.email"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: LogicalNot, Value: "!"},
+				{ID: Missing, Value: "name"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "(exists(a)) || b == null",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: OpenGroup, Value: "("},
+				{ID: Exists, Value: "a"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: LogicalOr, Value: "||"},
+				{ID: Expr, Value: "b"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "null"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "exists(a",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "a"},
+				{ID: Unknown},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensBoolean(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: `enabled == true && debug != "false"`,
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "enabled"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Boolean, Value: "true"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "debug"},
+				{ID: LogicalInvert, Value: "!="},
+				{ID: Literal, Value: `"false"`},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "enabled && (count) || !debug",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "enabled"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: OpenGroup, Value: "("},
+				{ID: Expr, Value: "count"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: LogicalOr, Value: "||"},
+				{ID: LogicalNot, Value: "!"},
+				{ID: Expr, Value: "debug"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "a | b",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "a"},
+				{ID: Unknown, Value: "|"},
+				{ID: Expr, Value: "b"},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensTime(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: "age > 15m && created >= 2026-01-01T00:00:00Z",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "age"},
+				{ID: LogicalGreaterThan, Value: ">"},
+				{ID: Duration, Value: "15m"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "created"},
+				{ID: LogicalGreaterThanOrEqual, Value: ">="},
+				{ID: Timestamp, Value: "2026-01-01T00:00:00Z"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "(latency<=1h30m) || port in (5m, 10)",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: OpenGroup, Value: "("},
+				{ID: Expr, Value: "latency"},
+				{ID: LogicalLessThanOrEqual, Value: "<="},
+				{ID: Duration, Value: "1h30m"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: LogicalOr, Value: "||"},
+				{ID: Expr, Value: "port"},
+				{ID: LogicalIn, Value: "in"},
+				{ID: OpenList, Value: "("},
+				{ID: Match, Value: "5m"},
+				{ID: Separator, Value: ","},
+				{ID: Match, Value: "10"},
+				{ID: CloseList, Value: ")"},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensArithmetic(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: "used / total * 100 > 90",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "used"},
+				{ID: ArithmeticDivide, Value: "/"},
+				{ID: Expr, Value: "total"},
+				{ID: ArithmeticMultiply, Value: "*"},
+				{ID: Expr, Value: "100"},
+				{ID: LogicalGreaterThan, Value: ">"},
+				{ID: Match, Value: "90"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "-(a - b) % 2 <= -c + 1",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: ArithmeticNegate, Value: "-"},
+				{ID: OpenGroup, Value: "("},
+				{ID: Expr, Value: "a"},
+				{ID: ArithmeticSubtract, Value: "-"},
+				{ID: Expr, Value: "b"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: ArithmeticModulo, Value: "%"},
+				{ID: Expr, Value: "2"},
+				{ID: LogicalLessThanOrEqual, Value: "<="},
+				{ID: Match, Value: "-c"},
+				{ID: ArithmeticAdd, Value: "+"},
+				{ID: Expr, Value: "1"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "x > -(y) && a-b *= c",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "x"},
+				{ID: LogicalGreaterThan, Value: ">"},
+				{ID: ArithmeticNegate, Value: "-"},
+				{ID: OpenGroup, Value: "("},
+				{ID: Expr, Value: "y"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "a-b"},
+				{ID: LogicalContains, Value: "*="},
+				{ID: Match, Value: "c"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "a == 2+2 && b > -$c*2 && d == 10.0.0.0/8",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "a"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "2"},
+				{ID: ArithmeticAdd, Value: "+"},
+				{ID: Expr, Value: "2"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "b"},
+				{ID: LogicalGreaterThan, Value: ">"},
+				{ID: Match, Value: "-$c"},
+				{ID: ArithmeticMultiply, Value: "*"},
+				{ID: Expr, Value: "2"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "d"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: CIDR, Value: "10.0.0.0/8"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "used/total*100 > 90 && retries+1 >= max_retries && (a)%2",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "used"},
+				{ID: ArithmeticDivide, Value: "/"},
+				{ID: Expr, Value: "total"},
+				{ID: ArithmeticMultiply, Value: "*"},
+				{ID: Expr, Value: "100"},
+				{ID: LogicalGreaterThan, Value: ">"},
+				{ID: Match, Value: "90"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "retries"},
+				{ID: ArithmeticAdd, Value: "+"},
+				{ID: Expr, Value: "1"},
+				{ID: LogicalGreaterThanOrEqual, Value: ">="},
+				{ID: Match, Value: "max_retries"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: OpenGroup, Value: "("},
+				{ID: Expr, Value: "a"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: ArithmeticModulo, Value: "%"},
+				{ID: Expr, Value: "2"},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensFunction(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: `lower(user.name) == "admin"`,
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: FunctionCall, Value: "lower"},
+				{ID: Expr, Value: "user.name"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Literal, Value: `"admin"`},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "abs(a - b) > len(tags) && (name ^= lower(trim(prefix)))",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: FunctionCall, Value: "abs"},
+				{ID: Expr, Value: "a"},
+				{ID: ArithmeticSubtract, Value: "-"},
+				{ID: Expr, Value: "b"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: LogicalGreaterThan, Value: ">"},
+				{ID: FunctionCall, Value: "len"},
+				{ID: Expr, Value: "tags"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: OpenGroup, Value: "("},
+				{ID: Expr, Value: "name"},
+				{ID: LogicalStartsWith, Value: "^="},
+				{ID: FunctionCall, Value: "lower"},
+				{ID: FunctionCall, Value: "trim"},
+				{ID: Expr, Value: "prefix"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: `has_role(user, "admin") && x == "y"`,
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: FunctionCall, Value: "has_role"},
+				{ID: Expr, Value: "user"},
+				{ID: Separator, Value: ","},
+				{ID: Literal, Value: `"admin"`},
+				{ID: CloseGroup, Value: ")"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "x"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Literal, Value: `"y"`},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "f(a, (b)) == x,y",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: FunctionCall, Value: "f"},
+				{ID: Expr, Value: "a"},
+				{ID: Separator, Value: ","},
+				{ID: OpenGroup, Value: "("},
+				{ID: Expr, Value: "b"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: Match, Value: "x,y"},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensOperator(t *testing.T) {
+	operators := newTestOperators(t).sorted()
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: `name ~~ "jon" && location within london`,
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "name"},
+				{ID: LogicalCustom, Value: "~~"},
+				{ID: Literal, Value: `"jon"`},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "location"},
+				{ID: LogicalCustom, Value: "within"},
+				{ID: Match, Value: "london"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "lower(name)~~jon || within_range %% 2 || a ~= b*",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: FunctionCall, Value: "lower"},
+				{ID: Expr, Value: "name"},
+				{ID: CloseGroup, Value: ")"},
+				{ID: LogicalCustom, Value: "~~"},
+				{ID: Match, Value: "jon"},
+				{ID: LogicalOr, Value: "||"},
+				{ID: Expr, Value: "within_range"},
+				{ID: LogicalCustom, Value: "%%"},
+				{ID: Match, Value: "2"},
+				{ID: LogicalOr, Value: "||"},
+				{ID: Expr, Value: "a"},
+				{ID: LogicalGlob, Value: "~="},
+				{ID: Match, Value: "b*"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "~~ a",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "~~ a"},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens, operators...)
+	}
+}
+
+func TestParseTokensVersion(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: "version >= v1.12.0 && agent < 2.0.0-rc.1+build.5",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "version"},
+				{ID: LogicalGreaterThanOrEqual, Value: ">="},
+				{ID: SemVer, Value: "v1.12.0"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "agent"},
+				{ID: LogicalLessThan, Value: "<"},
+				{ID: SemVer, Value: "2.0.0-rc.1+build.5"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "version in (1.2.3, 1.2)",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "version"},
+				{ID: LogicalIn, Value: "in"},
+				{ID: OpenList, Value: "("},
+				{ID: Match, Value: "1.2.3"},
+				{ID: Separator, Value: ","},
+				{ID: Match, Value: "1.2"},
+				{ID: CloseList, Value: ")"},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestParseTokensNetwork(t *testing.T) {
+	cs := []struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: "src_ip in 10.0.0.0/8 && dst_ip == 192.168.1.5",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "src_ip"},
+				{ID: LogicalIn, Value: "in"},
+				{ID: CIDR, Value: "10.0.0.0/8"},
+				{ID: LogicalAnd, Value: "&&"},
+				{ID: Expr, Value: "dst_ip"},
+				{ID: LogicalEqual, Value: "=="},
+				{ID: IPAddress, Value: "192.168.1.5"},
+				{ID: EOF},
+			},
+		},
+		{
+			Input: "ip not in fe80::/10 || ip in (::1, 10.0.0.0/8)",
+			Tokens: []Token{
+				{ID: Entry},
+				{ID: Expr, Value: "ip"},
+				{ID: LogicalNotIn, Value: "not in"},
+				{ID: CIDR, Value: "fe80::/10"},
+				{ID: LogicalOr, Value: "||"},
+				{ID: Expr, Value: "ip"},
+				{ID: LogicalIn, Value: "in"},
+				{ID: OpenList, Value: "("},
+				{ID: IPAddress, Value: "::1"},
+				{ID: Separator, Value: ","},
+				{ID: CIDR, Value: "10.0.0.0/8"},
+				{ID: CloseList, Value: ")"},
+				{ID: EOF},
+			},
+		},
+	}
+	for i, x := range cs {
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
 func TestTokenParserTrimSpace(t *testing.T) {
 	cs := []struct {
 		Input  string
@@ -261,11 +1014,7 @@ func TestTokenParserTrimSpace(t *testing.T) {
 		},
 	}
 	for i, x := range cs {
-		var index = 0
-		for item := range newTokenizer(x.Input) {
-			checkToken(t, i, index, x.Tokens, item)
-			index++
-		}
+		checkTokens(t, i, x.Input, x.Tokens)
 	}
 }
 
@@ -326,10 +1075,54 @@ func TestParseTokensRegexes(t *testing.T) {
 		},
 	}
 	for i, x := range cs {
-		var index = 0
-		for item := range newTokenizer(x.Input) {
-			checkToken(t, i, index, x.Tokens, item)
-			index++
+		checkTokens(t, i, x.Input, x.Tokens)
+	}
+}
+
+func TestTokenizerNext(t *testing.T) {
+	tk := newTokenizer("test == 1", nil)
+	expected := []Token{
+		{ID: Entry, Start: 0, End: 0},
+		{ID: Expr, Value: "test", Start: 0, End: 5},
+		{ID: LogicalEqual, Value: "==", Start: 5, End: 7},
+		{ID: Match, Value: "1", Start: 7, End: 9},
+		{ID: EOF, Start: 9, End: 9},
+	}
+	for _, x := range expected {
+		token, err := tk.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, x, token)
+	}
+	for i := 0; i < 2; i++ {
+		token, err := tk.Next()
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, Token{}, token)
+	}
+}
+
+func BenchmarkTokenizer(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tk := newTokenizer("(test == 1 && test > 5) || name =~ /^test/ && all(ports) < 1024", nil)
+		for {
+			if _, err := tk.Next(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+// checkTokens is responsible for extracting the tokens from the input and comparing
+func checkTokens(t *testing.T, cs int, input string, expected []Token, operators ...*Operator) {
+	tk := newTokenizer(input, operators)
+	for index := 0; ; index++ {
+		item, err := tk.Next()
+		if err == io.EOF {
+			assert.Equal(t, len(expected), index, "case %d, expected: %d tokens, got: %d", cs, len(expected), index)
+			return
+		}
+		if !checkToken(t, cs, index, expected, item) {
+			return
 		}
 	}
 }
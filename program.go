@@ -0,0 +1,49 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+// Compile is responsible for parsing the input once into a program which can be evaluated
+// many times; the operations are resolved and the regexes compiled up front
+func Compile(input string, options ...Option) (*Program, error) {
+	root, err := New(input, options...).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Program{input: input, root: root}, nil
+}
+
+// MustCompile is like Compile but panics if the input cannot be parsed
+func MustCompile(input string, options ...Option) *Program {
+	p, err := Compile(input, options...)
+	if err != nil {
+		panic("lex: compile(" + input + "): " + err.Error())
+	}
+
+	return p
+}
+
+// Evaluate is responsible for evaluating the program, using the value function to resolve
+// the selectors. The program is never modified and is safe to evaluate concurrently
+func (p *Program) Evaluate(fn ValueFn) (bool, error) {
+	return p.root.Evaluate(fn)
+}
+
+// String returns the input the program was compiled from
+func (p *Program) String() string {
+	return p.input
+}
@@ -0,0 +1,295 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const benchmarkInput = "name =~ /^test/ && age >= 21 && all(ports) < 1024 || country == uk"
+
+var benchmarkValues = map[string][]interface{}{
+	"name":    {"testing"},
+	"age":     {30},
+	"ports":   {22, 80, 443},
+	"country": {"us"},
+}
+
+func benchmarkValueFn(selector string) ([]interface{}, error) {
+	return benchmarkValues[selector], nil
+}
+
+func TestCompile(t *testing.T) {
+	p, err := Compile("test == 1")
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+	assert.Equal(t, "test == 1", p.String())
+}
+
+func TestCompileBad(t *testing.T) {
+	p, err := Compile("test >")
+	assert.Error(t, err)
+	assert.Nil(t, p)
+}
+
+func TestCompileWithFunctions(t *testing.T) {
+	roles := map[string][]string{"bob": {"admin", "dev"}}
+	r := NewFunctionRegistry()
+	assert.NoError(t, r.Register("has_role", Function{
+		Parameters: []ValueType{ValueTypeString, ValueTypeString},
+		Returns:    ValueTypeBoolean,
+		Call: func(arguments [][]interface{}) ([]interface{}, error) {
+			if len(arguments[0]) != 1 || len(arguments[1]) != 1 {
+				return nil, nil
+			}
+			for _, x := range roles[arguments[0][0].(string)] {
+				if x == arguments[1][0] {
+					return []interface{}{true}, nil
+				}
+			}
+			return []interface{}{false}, nil
+		},
+	}))
+	assert.NoError(t, r.Register("geo_distance", Function{
+		Parameters: []ValueType{ValueTypeNumber, ValueTypeNumber, ValueTypeNumber, ValueTypeNumber},
+		Returns:    ValueTypeNumber,
+		Call: func(arguments [][]interface{}) ([]interface{}, error) {
+			var x []float64
+			for _, v := range arguments {
+				if len(v) != 1 {
+					return nil, nil
+				}
+				x = append(x, v[0].(float64))
+			}
+			return []interface{}{math.Hypot(x[0]-x[2], x[1]-x[3])}, nil
+		},
+	}))
+	values := map[string][]interface{}{
+		"user": {"bob"},
+		"lat":  {"54.5"},
+		"lon":  {3.9},
+	}
+	fn := func(selector string) ([]interface{}, error) {
+		return values[selector], nil
+	}
+	cs := []struct {
+		Input    string
+		Expected bool
+	}{
+		{Input: `has_role(user, "admin")`, Expected: true},
+		{Input: `has_role(user, 'ops')`},
+		{Input: `!has_role(user, "ops") && has_role(user, "dev") == true`, Expected: true},
+		{Input: "geo_distance(lat, lon, 51.5, -0.1) < 10", Expected: true},
+		{Input: "geo_distance(lat, lon, 51.5, -0.1) * 2 >= 10", Expected: true},
+		{Input: "geo_distance(lat, missing, 51.5, -0.1) < 10"},
+		{Input: `lower(user) == bob && has_role(lower("BOB"), "admin")`, Expected: true},
+	}
+	for i, c := range cs {
+		p, err := Compile(c.Input, WithFunctions(r))
+		if !assert.NoError(t, err, "case %d, input: %s", i, c.Input) {
+			continue
+		}
+		matched, err := p.Evaluate(fn)
+		assert.NoError(t, err, "case %d, input: %s", i, c.Input)
+		assert.Equal(t, c.Expected, matched, "case %d, input: %s", i, c.Input)
+	}
+}
+
+func TestCompileWithFunctionsBad(t *testing.T) {
+	r := NewFunctionRegistry()
+	assert.NoError(t, r.Register("has_role", Function{
+		Parameters: []ValueType{ValueTypeString, ValueTypeString},
+		Returns:    ValueTypeBoolean,
+		Call: func([][]interface{}) ([]interface{}, error) {
+			return nil, errors.New("failed")
+		},
+	}))
+	cs := []struct {
+		Input string
+		Code  ErrorCode
+	}{
+		{Input: `has_role(user) == true`, Code: CodeInvalidArguments},
+		{Input: `has_role(user, 1) == true`, Code: CodeInvalidArguments},
+		{Input: `has_role(user, "admin") > 1`, Code: CodeInvalidValue},
+		{Input: `has_role(user, "admin") + 1 == 2`, Code: CodeInvalidValue},
+		{Input: `abs(has_role(user, "admin")) == 1`, Code: CodeInvalidArguments},
+		{Input: `other(user) == 1`, Code: CodeUnknownFunction},
+	}
+	for i, c := range cs {
+		_, err := Compile(c.Input, WithFunctions(r))
+		var e *ParseError
+		if assert.True(t, errors.As(err, &e), "case %d, input: %s expected a parse error", i, c.Input) {
+			assert.Equal(t, c.Code, e.Code, "case %d, input: %s, error: %s", i, c.Input, e)
+		}
+	}
+	_, err := Compile(`has_role(user, "admin")`)
+	assert.Error(t, err)
+
+	matched, err := MustCompile(`has_role(user, "admin")`, WithFunctions(r)).Evaluate(benchmarkValueFn)
+	assert.Error(t, err)
+	assert.False(t, matched)
+}
+
+func TestCompileWithOperators(t *testing.T) {
+	values := map[string][]interface{}{
+		"name":     {"Jonathan"},
+		"names":    {"jon", "bob"},
+		"location": {[2]float64{51.4, -0.2}},
+		"count":    {12},
+		"divisor":  {3},
+		"short":    {"jon"},
+	}
+	fn := func(selector string) ([]interface{}, error) {
+		return values[selector], nil
+	}
+	r := newTestOperators(t)
+	cs := []struct {
+		Input    string
+		Expected bool
+	}{
+		{Input: `name ~~ "JON"`, Expected: true},
+		{Input: "name ~~ bob"},
+		{Input: "any(names) ~~ bo && !(all(names) ~~ bo)", Expected: true},
+		{Input: "location within london", Expected: true},
+		{Input: "count %% 4 && count + 1 %% 13 && !(count %% 5)", Expected: true},
+		{Input: "count %% 2 * 3", Expected: true},
+		{Input: "upper(name) ~~ lower($name)", Expected: true},
+		{Input: "count %% $divisor && name ~~ $short && !(count %% $count - 1)", Expected: true},
+		{Input: "count %% $missing || name ~~ $missing"},
+		{Input: "missing ~~ a || !(missing ~~ a)"},
+		{Input: `name ~= "Jon*" && name $= "than" && name ^= "Jon" && name *= "nat"`, Expected: true},
+	}
+	for i, c := range cs {
+		p, err := Compile(c.Input, WithOperators(r), WithFunctions(NewFunctionRegistry()))
+		if !assert.NoError(t, err, "case %d, input: %s", i, c.Input) {
+			continue
+		}
+		matched, err := p.Evaluate(fn)
+		assert.NoError(t, err, "case %d, input: %s", i, c.Input)
+		assert.Equal(t, c.Expected, matched, "case %d, input: %s", i, c.Input)
+	}
+	matched, err := MustCompile("location within paris", WithOperators(r)).Evaluate(fn)
+	assert.Error(t, err)
+	assert.False(t, matched)
+}
+
+func TestCompileWithOperatorsBad(t *testing.T) {
+	r := newTestOperators(t)
+	cs := []struct {
+		Input string
+		Code  ErrorCode
+	}{
+		{Input: "count %% abc", Code: CodeInvalidValue},
+		{Input: "count %% lower(name)", Code: CodeInvalidValue},
+		{Input: "lower(name) %% 2", Code: CodeInvalidValue},
+		{Input: "count %%", Code: CodeInvalidValue},
+		{Input: "count %% 2 %% 3", Code: CodeInvalidValue},
+		{Input: "(count == 1) ~~ a", Code: CodeUnexpectedToken},
+	}
+	for i, c := range cs {
+		_, err := Compile(c.Input, WithOperators(r))
+		var e *ParseError
+		if assert.True(t, errors.As(err, &e), "case %d, input: %s expected a parse error", i, c.Input) {
+			assert.Equal(t, c.Code, e.Code, "case %d, input: %s, error: %s", i, c.Input, e)
+		}
+	}
+	_, err := Compile("name ~~ jon")
+	assert.Error(t, err)
+}
+
+func TestMustCompile(t *testing.T) {
+	assert.NotPanics(t, func() { MustCompile("test == 1") })
+	assert.Panics(t, func() { MustCompile("test == )") })
+}
+
+func TestProgramEvaluate(t *testing.T) {
+	p := MustCompile(benchmarkInput)
+	for i := 0; i < 3; i++ {
+		matched, err := p.Evaluate(benchmarkValueFn)
+		assert.NoError(t, err)
+		assert.True(t, matched)
+	}
+	matched, err := p.Evaluate(func(string) ([]interface{}, error) {
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	assert.False(t, matched)
+}
+
+func TestProgramEvaluateConcurrent(t *testing.T) {
+	p := MustCompile(benchmarkInput)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				matched, err := p.Evaluate(benchmarkValueFn)
+				assert.NoError(t, err)
+				assert.True(t, matched)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkCompile(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Compile(benchmarkInput); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseAndEvaluate(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := New(benchmarkInput).Evaluate(benchmarkValueFn); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramEvaluate(b *testing.B) {
+	p := MustCompile(benchmarkInput)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Evaluate(benchmarkValueFn); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramEvaluateParallel(b *testing.B) {
+	p := MustCompile(benchmarkInput)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := p.Evaluate(benchmarkValueFn); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+const (
+	// QuantifierAny indicates at least one value must match, this is the default
+	QuantifierAny QuantifierType = 0
+	// QuantifierAll indicates every value must match
+	QuantifierAll QuantifierType = 1
+)
+
+// String returns a string representation of the quantifier
+func (q *QuantifierType) String() string {
+	if *q == QuantifierAll {
+		return "all"
+	}
+
+	return "any"
+}
+
+// getQuantifier converts the token value to the quantifier
+func getQuantifier(value string) QuantifierType {
+	if value == "all" {
+		return QuantifierAll
+	}
+
+	return QuantifierAny
+}
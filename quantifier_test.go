@@ -0,0 +1,41 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantifierString(t *testing.T) {
+	cs := []struct {
+		Quantifier QuantifierType
+		Expected   string
+	}{
+		{Quantifier: QuantifierAny, Expected: "any"},
+		{Quantifier: QuantifierAll, Expected: "all"},
+	}
+	for _, c := range cs {
+		assert.Equal(t, c.Expected, c.Quantifier.String())
+	}
+}
+
+func TestGetQuantifier(t *testing.T) {
+	assert.Equal(t, QuantifierAny, getQuantifier("any"))
+	assert.Equal(t, QuantifierAll, getQuantifier("all"))
+}
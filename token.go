@@ -43,10 +43,66 @@ func (t TokenID) String() string {
 		return "=="
 	case LogicalInvert:
 		return "!="
+	case LogicalNot:
+		return "!"
+	case LogicalIn:
+		return "in"
+	case LogicalNotIn:
+		return "not in"
+	case OpenList:
+		return "("
+	case CloseList:
+		return ")"
+	case Separator:
+		return ","
+	case LogicalStartsWith:
+		return "^="
+	case LogicalEndsWith:
+		return "$="
+	case LogicalContains:
+		return "*="
+	case LogicalEqualFold:
+		return "==i"
+	case LogicalGlob:
+		return "~="
+	case Exists:
+		return "exists"
+	case Missing:
+		return "missing"
+	case Boolean:
+		return "BOOLEAN"
+	case Duration:
+		return "DURATION"
+	case Timestamp:
+		return "TIMESTAMP"
+	case IPAddress:
+		return "IP"
+	case CIDR:
+		return "CIDR"
+	case SemVer:
+		return "SEMVER"
+	case ArithmeticAdd:
+		return "+"
+	case ArithmeticSubtract, ArithmeticNegate:
+		return "-"
+	case ArithmeticMultiply:
+		return "*"
+	case ArithmeticDivide:
+		return "/"
+	case ArithmeticModulo:
+		return "%"
+	case FunctionCall:
+		return "FUNCTION"
+	case LogicalCustom:
+		return "OPERATOR"
 	case Match:
 		return "MATCH"
+	case Literal:
+		return "LITERAL"
 	case Expr:
 		return "EXPR"
+	case Quantifier:
+		return "QUANTIFIER"
 	case EOF:
 		return "END"
 	case Entry:
@@ -0,0 +1,47 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+const (
+	// truthFalse means the expression is false
+	truthFalse truth = iota
+	// truthTrue means the expression is true
+	truthTrue
+	// truthUnknown means the expression could not be decided as a value was absent
+	truthUnknown
+)
+
+// toTruth converts a boolean into a truth
+func toTruth(v bool) truth {
+	if v {
+		return truthTrue
+	}
+
+	return truthFalse
+}
+
+// not returns the negation of the truth, the negation of unknown is unknown
+func (t truth) not() truth {
+	switch t {
+	case truthTrue:
+		return truthFalse
+	case truthFalse:
+		return truthTrue
+	}
+
+	return truthUnknown
+}
@@ -33,6 +33,24 @@ const (
 	LTE
 	// LIKE is a regex
 	LIKE
+	// IN means a member of the list
+	IN
+	// NOTIN means not a member of the list
+	NOTIN
+	// PREFIX means starts with
+	PREFIX
+	// SUFFIX means ends with
+	SUFFIX
+	// CONTAINS means contains the substring
+	CONTAINS
+	// EQI means equal to ignoring case
+	EQI
+	// GLOB is a shell style wildcard match
+	GLOB
+	// TRUTHY means the value is truthy, i.e. a bare selector
+	TRUTHY
+	// CUSTOM is a registered operation, i.e. name ~~ "jon"
+	CUSTOM
 )
 
 const (
@@ -68,4 +86,62 @@ const (
 	LogicalGreaterThan
 	// LogicalGreaterThanOrEqual means greater than or equal
 	LogicalGreaterThanOrEqual
+	// Quantifier is a quantifier applied to the values of a selector, i.e. any or all
+	Quantifier
+	// LogicalNot is a logical negation
+	LogicalNot
+	// Literal is a quoted string literal
+	Literal
+	// LogicalIn is a list membership operation
+	LogicalIn
+	// LogicalNotIn is a negated list membership operation
+	LogicalNotIn
+	// OpenList is the start of a list of values
+	OpenList
+	// CloseList is the end of a list of values
+	CloseList
+	// Separator separates the values in a list
+	Separator
+	// LogicalStartsWith is a string prefix operation
+	LogicalStartsWith
+	// LogicalEndsWith is a string suffix operation
+	LogicalEndsWith
+	// LogicalContains is a substring operation
+	LogicalContains
+	// LogicalEqualFold is a case insensitive equals operation
+	LogicalEqualFold
+	// LogicalGlob is a wildcard match operation
+	LogicalGlob
+	// Exists is a check the selector has a value, the value of the token is the selector
+	Exists
+	// Missing is a check the selector has no value, the value of the token is the selector
+	Missing
+	// Boolean is a true or false literal
+	Boolean
+	// Duration is a duration literal i.e. 15m or 250ms
+	Duration
+	// Timestamp is a RFC3339 timestamp literal
+	Timestamp
+	// IPAddress is an IPv4 or IPv6 address literal
+	IPAddress
+	// CIDR is a network prefix literal i.e. 10.0.0.0/8
+	CIDR
+	// SemVer is a semantic version literal i.e. v1.12.0
+	SemVer
+	// ArithmeticAdd is an addition
+	ArithmeticAdd
+	// ArithmeticSubtract is a subtraction
+	ArithmeticSubtract
+	// ArithmeticMultiply is a multiplication
+	ArithmeticMultiply
+	// ArithmeticDivide is a division
+	ArithmeticDivide
+	// ArithmeticModulo is the remainder of a division
+	ArithmeticModulo
+	// ArithmeticNegate is a unary minus
+	ArithmeticNegate
+	// FunctionCall is a function call, the value of the token is the name of the function
+	FunctionCall
+	// LogicalCustom is a registered operation, the value of the token is the symbol
+	LogicalCustom
 )
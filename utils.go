@@ -17,8 +17,14 @@ limitations under the License.
 package lex
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // parseIfFloat attempts to parse to a float or returns the input
@@ -31,3 +37,370 @@ func parseIfFloat(in string) (bool, interface{}) {
 
 	return true, v
 }
+
+// unquote is responsible for removing the quotes from a string literal and decoding the
+// escape sequences \", \', \\, \n, \t and \uXXXX
+func unquote(in string) (string, error) {
+	if len(in) < 2 || (in[0] != '"' && in[0] != '\'') || in[len(in)-1] != in[0] {
+		return "", errors.New("string is not terminated")
+	}
+	body := in[1 : len(in)-1]
+
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' {
+			b.WriteByte(body[i])
+			continue
+		}
+		if i++; i >= len(body) {
+			return "", errors.New("string is not terminated")
+		}
+		switch body[i] {
+		case '"', '\'', '\\':
+			b.WriteByte(body[i])
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if i+5 > len(body) {
+				return "", errors.New("invalid unicode escape sequence")
+			}
+			r, err := strconv.ParseUint(body[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", errors.New("invalid unicode escape sequence")
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c", body[i])
+		}
+	}
+
+	return b.String(), nil
+}
+
+// globToRegex is responsible for converting a shell style glob into an anchored regex, a
+// '*' matches within a path segment, '**' matches across segments, '?' matches a single
+// character and '[...]' a character class, negated with a leading '!'
+func globToRegex(pattern string) (string, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				// step: a '**/' also matches no directories at all
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+					continue
+				}
+				b.WriteString(".*")
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return "", errors.New("character class is not terminated")
+			}
+			class := pattern[i+1 : i+1+end]
+			if class == "" || class == "!" {
+				return "", errors.New("character class is empty")
+			}
+			b.WriteString("[")
+			if class[0] == '!' {
+				b.WriteString("^")
+				class = class[1:]
+			}
+			b.WriteString(regexp.QuoteMeta(class) + "]")
+			i += end + 1
+		case '\\':
+			if i++; i >= len(pattern) {
+				return "", errors.New("escape sequence is not terminated")
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	return b.String(), nil
+}
+
+// toFloat attempts to coerce the value into a float64
+func toFloat(in interface{}) (float64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case string:
+		if found, x := parseIfFloat(v); found {
+			return x.(float64), true
+		}
+	case []byte:
+		return toFloat(string(v))
+	}
+
+	return 0, false
+}
+
+// isDuration checks if the value is a duration i.e. 15m, the numbers are not durations
+func isDuration(in string) bool {
+	if found, _ := parseIfFloat(in); found {
+		return false
+	}
+	_, err := time.ParseDuration(in)
+
+	return err == nil
+}
+
+// isTimestamp checks if the value is a RFC3339 timestamp
+func isTimestamp(in string) bool {
+	_, err := time.Parse(time.RFC3339, in)
+
+	return err == nil
+}
+
+// isVersion checks if the value is a semantic version i.e. v1.12.0
+func isVersion(in string) bool {
+	_, err := ParseVersion(in)
+
+	return err == nil
+}
+
+// isAddress checks if the value is an IPv4 or IPv6 address
+func isAddress(in string) bool {
+	_, err := netip.ParseAddr(in)
+
+	return err == nil
+}
+
+// isPrefix checks if the value is a network prefix i.e. 10.0.0.0/8
+func isPrefix(in string) bool {
+	_, err := netip.ParsePrefix(in)
+
+	return err == nil
+}
+
+// toAddr attempts to coerce the value into an address, IPv4 mapped IPv6 addresses are
+// converted to IPv4
+func toAddr(in interface{}) (netip.Addr, bool) {
+	switch v := in.(type) {
+	case netip.Addr:
+		return v.Unmap(), v.IsValid()
+	case net.IP:
+		x, found := netip.AddrFromSlice(v)
+		return x.Unmap(), found
+	case string:
+		if x, err := netip.ParseAddr(v); err == nil {
+			return x.Unmap(), true
+		}
+	case []byte:
+		return toAddr(string(v))
+	}
+
+	return netip.Addr{}, false
+}
+
+// toPrefix attempts to coerce the value into a network prefix
+func toPrefix(in interface{}) (netip.Prefix, bool) {
+	switch v := in.(type) {
+	case netip.Prefix:
+		return v.Masked(), v.IsValid()
+	case *net.IPNet:
+		if v == nil {
+			break
+		}
+		return toPrefix(v.String())
+	case string:
+		if x, err := netip.ParsePrefix(v); err == nil {
+			return x.Masked(), true
+		}
+	case []byte:
+		return toPrefix(string(v))
+	}
+
+	return netip.Prefix{}, false
+}
+
+// toVersion attempts to coerce the value into a version, strings must be a semantic version
+func toVersion(in interface{}) (Version, bool) {
+	switch v := in.(type) {
+	case Version:
+		return v, true
+	case *Version:
+		if v != nil {
+			return *v, true
+		}
+	case string:
+		if x, err := ParseVersion(v); err == nil {
+			return x, true
+		}
+	case []byte:
+		return toVersion(string(v))
+	}
+
+	return Version{}, false
+}
+
+// toDuration attempts to coerce the value into a duration, strings must be a valid duration
+func toDuration(in interface{}) (time.Duration, bool) {
+	switch v := in.(type) {
+	case time.Duration:
+		return v, true
+	case string:
+		if x, err := time.ParseDuration(v); err == nil {
+			return x, true
+		}
+	case []byte:
+		return toDuration(string(v))
+	}
+
+	return 0, false
+}
+
+// toTime attempts to coerce the value into a time, strings must be a RFC3339 timestamp
+func toTime(in interface{}) (time.Time, bool) {
+	switch v := in.(type) {
+	case time.Time:
+		return v, true
+	case *time.Time:
+		if v != nil {
+			return *v, true
+		}
+	case string:
+		if x, err := time.Parse(time.RFC3339, v); err == nil {
+			return x, true
+		}
+	case []byte:
+		return toTime(string(v))
+	}
+
+	return time.Time{}, false
+}
+
+// toBool attempts to coerce the value into a boolean, strings must be true or false
+func toBool(in interface{}) (bool, bool) {
+	switch v := in.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(v) {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	case []byte:
+		return toBool(string(v))
+	}
+
+	return false, false
+}
+
+// isTruthy checks the value is truthy i.e. true, a non zero number or a non empty string
+// which is not false
+func isTruthy(in interface{}) bool {
+	if v, found := toBool(in); found {
+		return v
+	}
+	if v, found := toFloat(in); found {
+		return v != 0
+	}
+	if v, found := toString(in); found {
+		return v != ""
+	}
+
+	return false
+}
+
+// toMatch attempts to convert the value into a match for the operation, the string operations
+// compare the value as a string, otherwise strings are typed as a literal would be
+func toMatch(operation OperationID, in interface{}) (interface{}, bool) {
+	switch operation {
+	case PREFIX, SUFFIX, CONTAINS, EQI:
+		return toString(in)
+	}
+	switch v := in.(type) {
+	case bool, float64, time.Duration, time.Time, Version, netip.Addr, netip.Prefix:
+		return v, true
+	case []byte:
+		return toMatch(operation, string(v))
+	case string:
+		switch {
+		case v == "true" || v == "false":
+			return v == "true", true
+		case isDuration(v):
+			return toDuration(v)
+		case isTimestamp(v):
+			return toTime(v)
+		case isVersion(v):
+			return toVersion(v)
+		case isAddress(v):
+			return toAddr(v)
+		case isPrefix(v):
+			return toPrefix(v)
+		}
+		if found, x := parseIfFloat(v); found {
+			return x, true
+		}
+		return v, true
+	}
+	if v, found := toFloat(in); found {
+		return v, true
+	}
+	if v, found := toAddr(in); found {
+		return v, true
+	}
+	if v, found := toTime(in); found {
+		return v, true
+	}
+
+	return toString(in)
+}
+
+// toString attempts to coerce the value into a string
+func toString(in interface{}) (string, bool) {
+	switch v := in.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case fmt.Stringer:
+		return v.String(), true
+	}
+	if x, found := toFloat(in); found {
+		return strconv.FormatFloat(x, 'f', -1, 64), true
+	}
+
+	return "", false
+}
@@ -17,7 +17,11 @@ limitations under the License.
 package lex
 
 import (
+	"net"
+	"net/netip"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -55,3 +59,299 @@ func TestParseIfFloatBad(t *testing.T) {
 		assert.Equal(t, x.Expected, value, "case %d, expected: %v, got: %v", i, x.Expected, value)
 	}
 }
+
+func TestUnquoteOk(t *testing.T) {
+	cs := []struct {
+		Input    string
+		Expected string
+	}{
+		{Input: `""`, Expected: ""},
+		{Input: `"test"`, Expected: "test"},
+		{Input: `'test'`, Expected: "test"},
+		{Input: `"a || b"`, Expected: "a || b"},
+		{Input: `"say \"hi\""`, Expected: `say "hi"`},
+		{Input: `'it\'s'`, Expected: "it's"},
+		{Input: `"it's"`, Expected: "it's"},
+		{Input: `"c:\\temp"`, Expected: `c:\temp`},
+		{Input: `"a\nb\tc"`, Expected: "a\nb\tc"},
+		{Input: `"\u00e9t\u00E9"`, Expected: "\u00e9t\u00e9"},
+	}
+	for i, x := range cs {
+		value, err := unquote(x.Input)
+		assert.NoError(t, err, "case %d, input: %s should not have failed", i, x.Input)
+		assert.Equal(t, x.Expected, value, "case %d, expected: %s, got: %s", i, x.Expected, value)
+	}
+}
+
+func TestUnquoteBad(t *testing.T) {
+	cs := []string{
+		`"`,
+		`"test`,
+		`'test"`,
+		`test`,
+		`"test\"`,
+		`"\q"`,
+		`"\u00"`,
+		`"\uzzzz"`,
+	}
+	for i, x := range cs {
+		_, err := unquote(x)
+		assert.Error(t, err, "case %d, input: %s should have failed", i, x)
+	}
+}
+
+func TestGlobToRegex(t *testing.T) {
+	cs := []struct {
+		Pattern  string
+		Input    string
+		Expected bool
+	}{
+		{Pattern: "web-*.eu-?.internal", Input: "web-01.eu-1.internal", Expected: true},
+		{Pattern: "web-*.eu-?.internal", Input: "web-.eu-2.internal", Expected: true},
+		{Pattern: "web-*.eu-?.internal", Input: "web-01.eu-10.internal"},
+		{Pattern: "web-*.eu-?.internal", Input: "web-01.eu-1xinternal"},
+		{Pattern: "*.yaml", Input: "config.yaml", Expected: true},
+		{Pattern: "*.yaml", Input: "conf/config.yaml"},
+		{Pattern: "**/*.yaml", Input: "config.yaml", Expected: true},
+		{Pattern: "**/*.yaml", Input: "a/b/config.yaml", Expected: true},
+		{Pattern: "**/*.yaml", Input: "a/b/config.json"},
+		{Pattern: "a/**/b", Input: "a/b", Expected: true},
+		{Pattern: "a/**/b", Input: "a/x/y/b", Expected: true},
+		{Pattern: "a/**", Input: "a/x/y", Expected: true},
+		{Pattern: "[abc]-1", Input: "b-1", Expected: true},
+		{Pattern: "[!abc]-1", Input: "b-1"},
+		{Pattern: "[!abc]-1", Input: "d-1", Expected: true},
+		{Pattern: "node[0-9]", Input: "node7", Expected: true},
+		{Pattern: `a\*b`, Input: "a*b", Expected: true},
+		{Pattern: `a\*b`, Input: "axb"},
+		{Pattern: "a.b+c", Input: "a.b+c", Expected: true},
+		{Pattern: "a.b+c", Input: "axbbc"},
+	}
+	for i, c := range cs {
+		expr, err := globToRegex(c.Pattern)
+		if !assert.NoError(t, err, "case %d, pattern: %s", i, c.Pattern) {
+			continue
+		}
+		matched := regexp.MustCompile(expr).MatchString(c.Input)
+		assert.Equal(t, c.Expected, matched, "case %d, pattern: %s, input: %s", i, c.Pattern, c.Input)
+	}
+}
+
+func TestGlobToRegexBad(t *testing.T) {
+	cs := []string{
+		"[abc",
+		"[]",
+		"[!]",
+		`test\`,
+	}
+	for i, x := range cs {
+		_, err := globToRegex(x)
+		assert.Error(t, err, "case %d, pattern: %s should have failed", i, x)
+	}
+}
+
+func TestToBool(t *testing.T) {
+	cs := []struct {
+		Input    interface{}
+		Expected bool
+		Found    bool
+	}{
+		{Input: true, Expected: true, Found: true},
+		{Input: false, Found: true},
+		{Input: "true", Expected: true, Found: true},
+		{Input: "False", Found: true},
+		{Input: []byte("TRUE"), Expected: true, Found: true},
+		{Input: "yes"},
+		{Input: 1},
+		{Input: nil},
+	}
+	for i, c := range cs {
+		v, found := toBool(c.Input)
+		assert.Equal(t, c.Found, found, "case %d, input: %v", i, c.Input)
+		assert.Equal(t, c.Expected, v, "case %d, input: %v", i, c.Input)
+	}
+}
+
+func TestIsTruthy(t *testing.T) {
+	cs := []struct {
+		Input    interface{}
+		Expected bool
+	}{
+		{Input: true, Expected: true},
+		{Input: false},
+		{Input: 1, Expected: true},
+		{Input: 0},
+		{Input: -0.5, Expected: true},
+		{Input: "test", Expected: true},
+		{Input: "false"},
+		{Input: "0"},
+		{Input: ""},
+		{Input: struct{}{}},
+	}
+	for i, c := range cs {
+		assert.Equal(t, c.Expected, isTruthy(c.Input), "case %d, input: %v", i, c.Input)
+	}
+}
+
+func TestIsDuration(t *testing.T) {
+	assert.True(t, isDuration("15m"))
+	assert.True(t, isDuration("1h30m"))
+	assert.True(t, isDuration("250ms"))
+	assert.False(t, isDuration("0"))
+	assert.False(t, isDuration("15"))
+	assert.False(t, isDuration("test"))
+}
+
+func TestIsTimestamp(t *testing.T) {
+	assert.True(t, isTimestamp("2026-01-01T00:00:00Z"))
+	assert.True(t, isTimestamp("2026-01-01T00:00:00.5+01:00"))
+	assert.False(t, isTimestamp("2026-01-01"))
+	assert.False(t, isTimestamp("test"))
+}
+
+func TestIsVersion(t *testing.T) {
+	assert.True(t, isVersion("1.2.3"))
+	assert.True(t, isVersion("v2.0.0-rc.1+build.5"))
+	assert.False(t, isVersion("1.2"))
+	assert.False(t, isVersion("10.0.0.1"))
+	assert.False(t, isVersion("test"))
+}
+
+func TestToMatch(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cs := []struct {
+		Operation OperationID
+		Input     interface{}
+		Expected  interface{}
+		Found     bool
+	}{
+		{Operation: EQ, Input: "test", Expected: "test", Found: true},
+		{Operation: EQ, Input: "10", Expected: float64(10), Found: true},
+		{Operation: EQ, Input: 10, Expected: float64(10), Found: true},
+		{Operation: EQ, Input: "true", Expected: true, Found: true},
+		{Operation: GT, Input: "15m", Expected: 15 * time.Minute, Found: true},
+		{Operation: GT, Input: []byte("2026-01-01T00:00:00Z"), Expected: now, Found: true},
+		{Operation: GT, Input: &now, Expected: now, Found: true},
+		{Operation: GT, Input: "v1.2.3", Expected: Version{Major: 1, Minor: 2, Patch: 3}, Found: true},
+		{Operation: EQ, Input: net.ParseIP("10.0.0.1"), Expected: netip.MustParseAddr("10.0.0.1"), Found: true},
+		{Operation: EQ, Input: "10.0.0.0/8", Expected: netip.MustParsePrefix("10.0.0.0/8"), Found: true},
+		{Operation: PREFIX, Input: 10, Expected: "10", Found: true},
+		{Operation: EQI, Input: "15m", Expected: "15m", Found: true},
+		{Operation: EQ, Input: struct{}{}},
+	}
+	for i, c := range cs {
+		v, found := toMatch(c.Operation, c.Input)
+		assert.Equal(t, c.Found, found, "case %d, input: %v", i, c.Input)
+		if found {
+			assert.Equal(t, c.Expected, v, "case %d, input: %v", i, c.Input)
+		}
+	}
+}
+
+func TestToVersion(t *testing.T) {
+	v := Version{Major: 1, Minor: 2, Patch: 3}
+	cs := []struct {
+		Input    interface{}
+		Expected Version
+		Found    bool
+	}{
+		{Input: v, Expected: v, Found: true},
+		{Input: &v, Expected: v, Found: true},
+		{Input: "v1.2.3", Expected: v, Found: true},
+		{Input: []byte("1.2.3"), Expected: v, Found: true},
+		{Input: (*Version)(nil)},
+		{Input: "1.2"},
+		{Input: 10},
+	}
+	for i, c := range cs {
+		v, found := toVersion(c.Input)
+		assert.Equal(t, c.Found, found, "case %d, input: %v", i, c.Input)
+		assert.Equal(t, c.Expected, v, "case %d, input: %v", i, c.Input)
+	}
+}
+
+func TestToDuration(t *testing.T) {
+	cs := []struct {
+		Input    interface{}
+		Expected time.Duration
+		Found    bool
+	}{
+		{Input: time.Second, Expected: time.Second, Found: true},
+		{Input: "1m", Expected: time.Minute, Found: true},
+		{Input: []byte("2h"), Expected: 2 * time.Hour, Found: true},
+		{Input: "test"},
+		{Input: 10},
+	}
+	for i, c := range cs {
+		v, found := toDuration(c.Input)
+		assert.Equal(t, c.Found, found, "case %d, input: %v", i, c.Input)
+		assert.Equal(t, c.Expected, v, "case %d, input: %v", i, c.Input)
+	}
+}
+
+func TestToTime(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cs := []struct {
+		Input    interface{}
+		Expected time.Time
+		Found    bool
+	}{
+		{Input: now, Expected: now, Found: true},
+		{Input: &now, Expected: now, Found: true},
+		{Input: "2026-01-01T00:00:00Z", Expected: now, Found: true},
+		{Input: (*time.Time)(nil)},
+		{Input: "2026-01-01"},
+		{Input: 10},
+	}
+	for i, c := range cs {
+		v, found := toTime(c.Input)
+		assert.Equal(t, c.Found, found, "case %d, input: %v", i, c.Input)
+		assert.True(t, c.Expected.Equal(v), "case %d, input: %v", i, c.Input)
+	}
+}
+
+func TestToAddr(t *testing.T) {
+	cs := []struct {
+		Input    interface{}
+		Expected string
+		Found    bool
+	}{
+		{Input: "10.0.0.1", Expected: "10.0.0.1", Found: true},
+		{Input: "::ffff:10.0.0.1", Expected: "10.0.0.1", Found: true},
+		{Input: net.ParseIP("10.0.0.1"), Expected: "10.0.0.1", Found: true},
+		{Input: netip.MustParseAddr("fe80::1"), Expected: "fe80::1", Found: true},
+		{Input: []byte("::1"), Expected: "::1", Found: true},
+		{Input: netip.Addr{}},
+		{Input: "10.0.0.0/8"},
+		{Input: 10},
+	}
+	for i, c := range cs {
+		v, found := toAddr(c.Input)
+		assert.Equal(t, c.Found, found, "case %d, input: %v", i, c.Input)
+		if found {
+			assert.Equal(t, c.Expected, v.String(), "case %d, input: %v", i, c.Input)
+		}
+	}
+}
+
+func TestToPrefix(t *testing.T) {
+	_, network, _ := net.ParseCIDR("192.168.0.0/16")
+	cs := []struct {
+		Input    interface{}
+		Expected string
+		Found    bool
+	}{
+		{Input: "10.1.0.0/8", Expected: "10.0.0.0/8", Found: true},
+		{Input: network, Expected: "192.168.0.0/16", Found: true},
+		{Input: netip.MustParsePrefix("fe80::/10"), Expected: "fe80::/10", Found: true},
+		{Input: (*net.IPNet)(nil)},
+		{Input: "10.0.0.1"},
+	}
+	for i, c := range cs {
+		v, found := toPrefix(c.Input)
+		assert.Equal(t, c.Found, found, "case %d, input: %v", i, c.Input)
+		if found {
+			assert.Equal(t, c.Expected, v.String(), "case %d, input: %v", i, c.Input)
+		}
+	}
+}
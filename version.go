@@ -0,0 +1,168 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ParseVersion is responsible for parsing a semantic version, the leading 'v' is optional
+func ParseVersion(in string) (Version, error) {
+	v := Version{}
+	in = strings.TrimPrefix(in, "v")
+	if i := strings.IndexByte(in, '+'); i >= 0 {
+		v.Build = in[i+1:]
+		if !validIdentifiers(v.Build, false) {
+			return Version{}, errors.New("invalid build metadata")
+		}
+		in = in[:i]
+	}
+	if i := strings.IndexByte(in, '-'); i >= 0 {
+		if !validIdentifiers(in[i+1:], true) {
+			return Version{}, errors.New("invalid pre-release")
+		}
+		v.Prerelease = strings.Split(in[i+1:], ".")
+		in = in[:i]
+	}
+	parts := strings.Split(in, ".")
+	if len(parts) != 3 {
+		return Version{}, errors.New("version must have a major, minor and patch")
+	}
+	for i, x := range []*uint64{&v.Major, &v.Minor, &v.Patch} {
+		if !isNumeric(parts[i]) {
+			return Version{}, errors.New("invalid version number")
+		}
+		n, err := strconv.ParseUint(parts[i], 10, 64)
+		if err != nil {
+			return Version{}, errors.New("invalid version number")
+		}
+		*x = n
+	}
+
+	return v, nil
+}
+
+// Compare returns the precedence of the version against the other, which is negative when
+// the version is lower, zero when equal and positive when higher. A pre-release has a lower
+// precedence than the release and the build metadata is ignored
+func (v Version) Compare(o Version) int {
+	for _, x := range [][2]uint64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if x[0] != x[1] {
+			if x[0] < x[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(v.Prerelease) == 0 && len(o.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(o.Prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Prerelease) && i < len(o.Prerelease); i++ {
+		if c := compareIdentifier(v.Prerelease[i], o.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.Prerelease) < len(o.Prerelease):
+		return -1
+	case len(v.Prerelease) > len(o.Prerelease):
+		return 1
+	}
+
+	return 0
+}
+
+// String returns the version without the leading 'v'
+func (v Version) String() string {
+	s := strconv.FormatUint(v.Major, 10) + "." + strconv.FormatUint(v.Minor, 10) + "." + strconv.FormatUint(v.Patch, 10)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+
+	return s
+}
+
+// compareIdentifier compares two pre-release identifiers, numeric identifiers are compared
+// numerically and have a lower precedence than alphanumeric ones
+func compareIdentifier(a, b string) int {
+	an, bn := isDigits(a), isDigits(b)
+	switch {
+	case an && bn:
+		if len(a) != len(b) {
+			if len(a) < len(b) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	case an:
+		return -1
+	case bn:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// validIdentifiers checks the dot separated identifiers are non empty alphanumerics or
+// hyphens, when strict the numeric identifiers must not have leading zeros
+func validIdentifiers(in string, strict bool) bool {
+	for _, x := range strings.Split(in, ".") {
+		if x == "" {
+			return false
+		}
+		for _, c := range x {
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+				return false
+			}
+		}
+		if strict && isDigits(x) && !isNumeric(x) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isNumeric checks the value is a number without leading zeros
+func isNumeric(in string) bool {
+	return isDigits(in) && (in == "0" || in[0] != '0')
+}
+
+// isDigits checks the value is made up of digits only
+func isDigits(in string) bool {
+	if in == "" {
+		return false
+	}
+	for _, c := range in {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2017 Rohith Jayawardene <gambol99@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionParse(t *testing.T) {
+	cs := []struct {
+		Input    string
+		Expected Version
+		Ok       bool
+	}{
+		{Input: "1.2.3", Expected: Version{Major: 1, Minor: 2, Patch: 3}, Ok: true},
+		{Input: "v1.12.0", Expected: Version{Major: 1, Minor: 12}, Ok: true},
+		{Input: "2.0.0-rc.1", Expected: Version{Major: 2, Prerelease: []string{"rc", "1"}}, Ok: true},
+		{Input: "1.0.0-x-y.0+build.5", Expected: Version{Major: 1, Prerelease: []string{"x-y", "0"}, Build: "build.5"}, Ok: true},
+		{Input: "1.0.0+001", Expected: Version{Major: 1, Build: "001"}, Ok: true},
+		{Input: "1.2"},
+		{Input: "1.2.3.4"},
+		{Input: "01.2.3"},
+		{Input: "1.2.3-01"},
+		{Input: "1.2.3-"},
+		{Input: "1.2.3-rc..1"},
+		{Input: "1.2.3+"},
+		{Input: "1.2.x"},
+		{Input: "vv1.2.3"},
+	}
+	for i, c := range cs {
+		v, err := ParseVersion(c.Input)
+		if !c.Ok {
+			assert.Error(t, err, "case %d, input: %s should have failed", i, c.Input)
+			continue
+		}
+		if assert.NoError(t, err, "case %d, input: %s", i, c.Input) {
+			assert.Equal(t, c.Expected, v, "case %d, input: %s", i, c.Input)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	// step: the versions are in ascending order of precedence
+	ordering := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	for i := range ordering {
+		for j := range ordering {
+			a, b := mustVersion(ordering[i]), mustVersion(ordering[j])
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			assert.Equal(t, expected, a.Compare(b), "%s against %s", ordering[i], ordering[j])
+		}
+	}
+	assert.Equal(t, 0, mustVersion("1.0.0+build.1").Compare(mustVersion("v1.0.0+build.2")))
+}
+
+func TestVersionString(t *testing.T) {
+	for _, x := range []string{"1.2.3", "2.0.0-rc.1", "1.0.0-beta+exp.sha.5114f85"} {
+		assert.Equal(t, x, mustVersion(x).String())
+	}
+	assert.Equal(t, "1.12.0", mustVersion("v1.12.0").String())
+}
+
+func mustVersion(in string) Version {
+	v, err := ParseVersion(in)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}